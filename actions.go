@@ -1,11 +1,14 @@
 package commander
 
 import (
-	"encoding/json"
+	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"strconv"
 	"strings"
+	"sync"
+	"text/tabwriter"
 	"time"
 )
 
@@ -29,14 +32,67 @@ type Action interface {
 	Tags() []string
 }
 
+// ArgsPayloader is an optional sub-interface of Action.
+// When an action is ran by Commands.Run with words after its name, those words are given to
+// ArgsPayload instead of asking the interactive Payload questions.
+// Returning NoArgs falls back to the interactive Payload.
+type ArgsPayloader interface {
+	ArgsPayload(*Config, []string) (interface{}, error)
+}
+
+// IOArgsPayloader is an optional sub-interface of Action.
+// It is ArgsPayloader, but is also given the Commands' IO so it can ask for what the arguments left out.
+// Commands prefers IOArgsPayload over ArgsPayload when an action implements both.
+type IOArgsPayloader interface {
+	IOArgsPayload(*Config, *IO, []string) (interface{}, error)
+}
+
+// ConcurrencyKeyer is an optional sub-interface of Action.
+// When Commands has more than one worker, work of actions with a concurrency key can run
+// alongside work with other keys, while work that shares a key still runs in the order it was queued.
+// Actions without a key, or with the key "", run alone with the Config to themselves.
+type ConcurrencyKeyer interface {
+	ConcurrencyKey() string
+}
+
+// ExecuteReadOnly is an optional sub-interface of Action.
+// Actions that only read the Config return true from ReadOnly. Their work skips the work queue,
+// and runs right away alongside other read only work, only waiting on work that writes to the Config,
+// keyed work included.
+type ExecuteReadOnly interface {
+	ReadOnly() bool
+}
+
+// ContextExecuter is an optional sub-interface of Action.
+// Commands calls ExecuteCtx instead of Execute, with a context that is canceled when the work is canceled.
+type ContextExecuter interface {
+	ExecuteCtx(context.Context, *Config, interface{}) (interface{}, error)
+}
+
+// ReplayPayloader is an optional sub-interface of Action.
+// Commands.Replay gives ReplayPayload the payload read from a journal, which may have lost its type
+// on the way (json decodes every number as float64), and uses what it returns as the payload.
+type ReplayPayloader interface {
+	ReplayPayload(*Config, interface{}) (interface{}, error)
+}
+
 type builderAction struct {
 	name      Name
 	desc      Desc
 	payload   Payload
+	iopayload IOPayload
+	args      ArgsPayload
+	ioargs    IOArgsPayload
+	replay    ReplayPayload
 	execute   Execute
+	ctx       ExecuteCtx
 	additions Additions
 	removals  Removals
 	tags      Tags
+	key       string
+	readOnly  bool
+	meta      Meta
+	complete  Complete
 }
 
 // Override takes a parent Action as input, and returns an action builder
@@ -45,14 +101,43 @@ type builderAction struct {
 // The builderAction satisfies the Action interface, so it can, itself, be given
 // to Override().
 func Override(parent Action) *builderAction {
-	return &builderAction{
+	o := &builderAction{
 		name:      parent.Name,
+		desc:      parent.Desc,
 		payload:   parent.Payload,
 		execute:   parent.Execute,
 		additions: parent.Additions,
 		removals:  parent.Removals,
 		tags:      parent.Tags,
 	}
+	if p, ok := parent.(IOPayloader); ok {
+		o.iopayload = p.IOPayload
+	}
+	if p, ok := parent.(ArgsPayloader); ok {
+		o.args = p.ArgsPayload
+	}
+	if p, ok := parent.(IOArgsPayloader); ok {
+		o.ioargs = p.IOArgsPayload
+	}
+	if k, ok := parent.(ConcurrencyKeyer); ok {
+		o.key = k.ConcurrencyKey()
+	}
+	if r, ok := parent.(ExecuteReadOnly); ok {
+		o.readOnly = r.ReadOnly()
+	}
+	if e, ok := parent.(ContextExecuter); ok {
+		o.ctx = e.ExecuteCtx
+	}
+	if r, ok := parent.(ReplayPayloader); ok {
+		o.replay = r.ReplayPayload
+	}
+	if d, ok := parent.(Describer); ok {
+		o.meta = d.Meta()
+	}
+	if c, ok := parent.(Completer); ok {
+		o.complete = c.Complete
+	}
+	return o
 }
 
 // Build is a shortcut for calling Override(NopAction{}).  NopAction being an empty action
@@ -78,16 +163,63 @@ func (o *builderAction) WithDesc(d Desc) *builderAction {
 // it returns itself for chaining.
 func (o *builderAction) WithPayload(p Payload) *builderAction {
 	o.payload = p
+	o.iopayload = nil
+	return o
+}
+
+// WithIOPayload will return the result of "p" when the action's IOPayload() function is called
+// with the IO of the Commands running it. Calling Payload() directly will give "p" StdIO.
+// it returns itself for chaining.
+func (o *builderAction) WithIOPayload(p IOPayload) *builderAction {
+	o.payload = p.Payload()
+	o.iopayload = p
+	return o
+}
+
+// WithArgsPayload will return the result of "a" when the action's ArgsPayload() function is called.
+// The interactive payload is left as is, and is used when no arguments are given.
+// it returns itself for chaining.
+func (o *builderAction) WithArgsPayload(a ArgsPayload) *builderAction {
+	o.args = a
+	o.ioargs = nil
+	return o
+}
+
+// WithIOArgsPayload will return the result of "a" when the action's IOArgsPayload() function is called
+// with the IO of the Commands running it. Calling ArgsPayload() directly will give "a" StdIO.
+// it returns itself for chaining.
+func (o *builderAction) WithIOArgsPayload(a IOArgsPayload) *builderAction {
+	o.args = a.ArgsPayload()
+	o.ioargs = a
+	return o
+}
+
+// WithReplayPayload will return the result of "r" when the action's ReplayPayload() function is called,
+// see ReplayPayloader.
+// it returns itself for chaining.
+func (o *builderAction) WithReplayPayload(r ReplayPayload) *builderAction {
+	o.replay = r
 	return o
 }
 
 // WithExecute will return the result of "e" when the action's Execute() function is called.
 // it returns itself for chaining.
 func (o *builderAction) WithExecute(e Execute) *builderAction {
+	o.ctx = nil
 	o.execute = e
 	return o
 }
 
+// WithExecuteCtx will return the result of "e" when the action's ExecuteCtx() function is called.
+// Commands gives "e" a context that is canceled when the action's work is canceled.
+// Calling Execute() directly gives "e" context.Background().
+// it returns itself for chaining.
+func (o *builderAction) WithExecuteCtx(e ExecuteCtx) *builderAction {
+	o.execute = e.Execute()
+	o.ctx = e
+	return o
+}
+
 // WithAdditions will return the result of "a" when the action's Additions() function is called.
 // it returns itself for chaining.
 func (o *builderAction) WithAdditions(a Additions) *builderAction {
@@ -109,6 +241,50 @@ func (o *builderAction) WithTags(t Tags) *builderAction {
 	return o
 }
 
+// WithConcurrencyKey lets the action's work run alongside work with other keys.
+// Work that shares key still runs in order, see ConcurrencyKeyer.
+// it returns itself for chaining.
+func (o *builderAction) WithConcurrencyKey(key string) *builderAction {
+	o.key = key
+	return o
+}
+
+// WithReadOnly declares that the action's Execute only reads the Config, see ExecuteReadOnly.
+// it returns itself for chaining.
+func (o *builderAction) WithReadOnly(readOnly bool) *builderAction {
+	o.readOnly = readOnly
+	return o
+}
+
+// WithMeta documents the action with "m", see Describer.
+// it returns itself for chaining.
+func (o *builderAction) WithMeta(m Meta) *builderAction {
+	o.meta = m
+	return o
+}
+
+// WithUsage sets how the action is called from a command line, like "load <file>".
+// it returns itself for chaining.
+func (o *builderAction) WithUsage(usage string) *builderAction {
+	o.meta.Usage = usage
+	return o
+}
+
+// WithExamples sets example command lines of the action.
+// it returns itself for chaining.
+func (o *builderAction) WithExamples(examples ...string) *builderAction {
+	o.meta.Examples = examples
+	return o
+}
+
+// WithComplete will return the result of "c" when the action's Complete() function is called,
+// see Completer.
+// it returns itself for chaining.
+func (o *builderAction) WithComplete(c Complete) *builderAction {
+	o.complete = c
+	return o
+}
+
 // WithNameV creates a new Name func that returns n when the actions Name() method is called.
 // it returns itself for chaining.
 func (o *builderAction) WithNameV(n string) *builderAction {
@@ -124,15 +300,13 @@ func (o *builderAction) WithDescV(d string) *builderAction {
 // the builderAction's Payload method is called.
 // it returns itself for chaining.
 func (o *builderAction) WithPayloadV(p interface{}, err error) *builderAction {
-	o.payload = func(*Config) (interface{}, error) { return p, err }
-	return o
+	return o.WithPayload(func(*Config) (interface{}, error) { return p, err })
 }
 
 // WithForkPayloads creates a new Payload func by calling ForkPayloads with "p"
 // it returns itself for chaining.
 func (o *builderAction) WithForkPayloads(p map[string]Payload) *builderAction {
-	o.payload = ForkPayloads(p)
-	return o
+	return o.WithIOPayload(ForkPayloads(p))
 }
 
 // WithForkPayloadsV creates new Payload funcs that return the value stored at each key in "p".
@@ -146,36 +320,78 @@ func (o *builderAction) WithForkPayloadsV(p map[string]interface{}) *builderActi
 			return v, nil
 		}
 	}
-	o.payload = ForkPayloads(newMap)
-	return o
+	return o.WithIOPayload(ForkPayloads(newMap))
 }
+
+// WithQuestionsPayload creates a new Payload func that scans every kv in order,
+// and returns the answers as a map[string]interface{} keyed by KV.Key.
+// The kvs are also the action's command line flags, see ParseFlags. Required kvs that are not
+// given as flags are asked for, and the rest are given their Default.
+// The kvs are shown as the action's arguments by describe.
+// Replayed payloads, and those given to Commands.RunWithPayload, are converted back to the kvs' types with KV.Coerce,
+// and must pass the kvs' Validators, and have every Required kv, the way flags do.
+// it returns itself for chaining.
 func (o *builderAction) WithQuestionsPayload(kvs ...KV) *builderAction {
-	o.payload = func(*Config) (interface{}, error) {
+	o.meta.Args = kvs
+	o.WithReplayPayload(func(_ *Config, payload interface{}) (interface{}, error) {
+		m, ok := payload.(map[string]interface{})
+		if !ok {
+			return nil, TypeConvertErr(payload, map[string]interface{}{})
+		}
 		res := make(map[string]interface{})
 		for _, kv := range kvs {
-			key, value, err := kv.Scan()
+			v, err := kv.coerceValid(m[kv.Key])
+			if err != nil {
+				return nil, err
+			}
+			res[kv.Key] = v
+		}
+		return res, nil
+	})
+	o.WithIOArgsPayload(func(_ *Config, io *IO, args []string) (interface{}, error) {
+		res, missing, err := ParseFlags(kvs, args)
+		if err != nil {
+			return nil, err
+		}
+		for _, kv := range missing {
+			if !kv.Required {
+				res[kv.Key] = kv.Default
+				continue
+			}
+			key, value, err := kv.Scan(io)
 			if err != nil {
 				return nil, err
 			}
 			res[key] = value
 		}
 		return res, nil
-	}
-	return o
+	})
+	return o.WithIOPayload(func(_ *Config, io *IO) (interface{}, error) {
+		res := make(map[string]interface{})
+		for _, kv := range kvs {
+			key, value, err := kv.Scan(io)
+			if err != nil {
+				return nil, err
+			}
+			res[key] = value
+		}
+		return res, nil
+	})
 }
 
 // WithAggregatePayload makes a new Payload func by calling CombinePayloads() on "p".
 // The builderAction's Payload function is replaced by this new Payload function.
 // it returns itself for chaining.
 func (o *builderAction) WithAggregatePayload(p map[string]Payload) *builderAction {
-	o.payload = CombinePayloads(p)
-	return o
+	return o.WithPayload(CombinePayloads(p))
 }
 func (o *builderAction) WithExecuteV(result interface{}, err error) *builderAction {
+	o.ctx = nil
 	o.execute = func(*Config, interface{}) (interface{}, error) { return result, err }
 	return o
 }
 func (o *builderAction) WithExecuteMap(p func(*Config, map[string]interface{}) (interface{}, error)) *builderAction {
+	o.ctx = nil
 	o.execute = func(c *Config, q interface{}) (interface{}, error) {
 		converted, ok := q.(map[string]interface{})
 		if !ok {
@@ -186,6 +402,7 @@ func (o *builderAction) WithExecuteMap(p func(*Config, map[string]interface{}) (
 	return o
 }
 func (o *builderAction) WithExecuteSlice(p func(*Config, []interface{}) (interface{}, error)) *builderAction {
+	o.ctx = nil
 	o.execute = func(c *Config, q interface{}) (interface{}, error) {
 		converted, ok := q.([]interface{})
 		if !ok {
@@ -196,6 +413,7 @@ func (o *builderAction) WithExecuteSlice(p func(*Config, []interface{}) (interfa
 	return o
 }
 func (o *builderAction) WithExecuteString(pFunc func(*Config, string) (interface{}, error)) *builderAction {
+	o.ctx = nil
 	o.execute = func(c *Config, p interface{}) (interface{}, error) {
 		converted, ok := p.(string)
 		if !ok {
@@ -206,6 +424,7 @@ func (o *builderAction) WithExecuteString(pFunc func(*Config, string) (interface
 	return o
 }
 func (o *builderAction) WithExecuteInt64(pFunc func(*Config, int64) (interface{}, error)) *builderAction {
+	o.ctx = nil
 	o.execute = func(c *Config, p interface{}) (interface{}, error) {
 		converted, ok := p.(int64)
 		if !ok {
@@ -224,6 +443,7 @@ func (o *builderAction) WithExecuteInt64(pFunc func(*Config, int64) (interface{}
 	return o
 }
 func (o *builderAction) WithExecuteVoid(pFunc func(*Config) (interface{}, error)) *builderAction {
+	o.ctx = nil
 	o.execute = func(c *Config, _ interface{}) (interface{}, error) {
 		return pFunc(c)
 	}
@@ -232,6 +452,7 @@ func (o *builderAction) WithExecuteVoid(pFunc func(*Config) (interface{}, error)
 
 // Void Execute functions
 func (o *builderAction) WithVoidExecuteMap(p func(*Config, map[string]interface{}) error) *builderAction {
+	o.ctx = nil
 	o.execute = func(c *Config, q interface{}) (interface{}, error) {
 		converted, ok := q.(map[string]interface{})
 		if !ok {
@@ -242,6 +463,7 @@ func (o *builderAction) WithVoidExecuteMap(p func(*Config, map[string]interface{
 	return o
 }
 func (o *builderAction) WithVoidExecuteSlice(p func(*Config, []interface{}) error) *builderAction {
+	o.ctx = nil
 	o.execute = func(c *Config, q interface{}) (interface{}, error) {
 		converted, ok := q.([]interface{})
 		if !ok {
@@ -252,6 +474,7 @@ func (o *builderAction) WithVoidExecuteSlice(p func(*Config, []interface{}) erro
 	return o
 }
 func (o *builderAction) WithVoidExecuteString(pFunc func(*Config, string) error) *builderAction {
+	o.ctx = nil
 	o.execute = func(c *Config, p interface{}) (interface{}, error) {
 		converted, ok := p.(string)
 		if !ok {
@@ -262,6 +485,7 @@ func (o *builderAction) WithVoidExecuteString(pFunc func(*Config, string) error)
 	return o
 }
 func (o *builderAction) WithVoidExecuteInt64(pFunc func(*Config, int64) error) *builderAction {
+	o.ctx = nil
 	o.execute = func(c *Config, p interface{}) (interface{}, error) {
 		converted, ok := p.(int64)
 		if !ok {
@@ -280,6 +504,7 @@ func (o *builderAction) WithVoidExecuteInt64(pFunc func(*Config, int64) error) *
 	return o
 }
 func (o *builderAction) WithVoidExecuteVoid(pFunc func(*Config) error) *builderAction {
+	o.ctx = nil
 	o.execute = func(c *Config, _ interface{}) (interface{}, error) {
 		return nil, pFunc(c)
 	}
@@ -311,12 +536,55 @@ func (o *builderAction) Name() string                                          {
 func (o *builderAction) Desc() string                                          { return o.desc() }
 func (o builderAction) Tags() []string                                         { return o.tags() }
 
+func (o *builderAction) IOPayload(c *Config, io *IO) (interface{}, error) {
+	if o.iopayload != nil {
+		return o.iopayload(c, io)
+	}
+	return o.payload(c)
+}
+func (o *builderAction) ArgsPayload(c *Config, args []string) (interface{}, error) {
+	if o.args != nil {
+		return o.args(c, args)
+	}
+	return nil, NoArgs
+}
+func (o *builderAction) ExecuteCtx(ctx context.Context, c *Config, p interface{}) (interface{}, error) {
+	if o.ctx != nil {
+		return o.ctx(ctx, c, p)
+	}
+	return o.execute(c, p)
+}
+func (o *builderAction) ReplayPayload(c *Config, p interface{}) (interface{}, error) {
+	if o.replay != nil {
+		return o.replay(c, p)
+	}
+	return p, nil
+}
+func (o *builderAction) ConcurrencyKey() string { return o.key }
+func (o *builderAction) ReadOnly() bool         { return o.readOnly }
+func (o *builderAction) Meta() Meta             { return o.meta }
+func (o *builderAction) Complete(c *Config, args []string) []string {
+	if o.complete != nil {
+		return o.complete(c, args)
+	}
+	return nil
+}
+func (o *builderAction) IOArgsPayload(c *Config, io *IO, args []string) (interface{}, error) {
+	if o.ioargs != nil {
+		return o.ioargs(c, io, args)
+	}
+	return o.ArgsPayload(c, args)
+}
+
 // TODO sync these up with NopAction Better
 func NewName() Name { return func() string { return "" } }
 func NewDesc() Desc { return func() string { return "" } }
 func NewPayload() Payload {
 	return func(*Config) (_ interface{}, _ error) { return }
 }
+func NewArgsPayload() ArgsPayload {
+	return func(*Config, []string) (interface{}, error) { return nil, NoArgs }
+}
 func NewExecute() Execute {
 	return func(c *Config, p interface{}) (_ interface{}, _ error) { return }
 }
@@ -336,14 +604,30 @@ func (NopAction) Additions(c *Config) map[string]Action                     { re
 func (NopAction) Removals() []string                                        { return nil }
 func (NopAction) Tags() []string                                            { return nil }
 
-type LoadAction struct{}
+// LoadAction loads the Config from a file.
+// Codec decodes the file, JSONCodec is used when it is nil.
+// Factory makes the new Config it is decoded into, see Factory.
+type LoadAction struct {
+	Codec   Codec
+	Factory Factory
+}
 
-func (s LoadAction) Payload(conf *Config) (interface{}, error) {
+func (s LoadAction) Payload(conf *Config) (interface{}, error) { return s.IOPayload(conf, StdIO) }
+func (s LoadAction) IOPayload(conf *Config, io *IO) (interface{}, error) {
 	var filename string
 	//TODO if not already in result list
 
 	// this filename is given to Execute as a payload
-	return filename, scan("load file", &filename)
+	err := io.Scan("load file", &filename)
+	return filename, err
+}
+
+// ArgsPayload uses the first argument as the file to load
+func (s LoadAction) ArgsPayload(conf *Config, args []string) (interface{}, error) {
+	if len(args) == 0 {
+		return nil, NoArgs
+	}
+	return args[0], nil
 }
 
 func (s LoadAction) Execute(conf *Config, payload interface{}) (interface{}, error) {
@@ -360,53 +644,88 @@ func (s LoadAction) Execute(conf *Config, payload interface{}) (interface{}, err
 		e.WrapAssign(f)()
 	}
 
-	ReplaceDotSlash(&filename)
-	ReplaceHome(&filename)
-
+	next(func(err *error) { *err = expandPath(&filename) })
 	next(func(err *error) { d, *err = ioutil.ReadFile(filename) })
-	next(func(err *error) { *err = json.Unmarshal(d, &c) })
+	next(func(err *error) { c, *err = decodeConfig(codecOr(s.Codec), s.Factory, d, *conf) })
 
 	if e.Err() != nil {
-		fmt.Printf("error was encountered loading file: \n\t%s\nerror:\n\t%v\n", filename, e.Err())
+		return nil, fmt.Errorf("error was encountered loading file: \n\t%s\nerror:\n\t%v", filename, e.Err())
 	}
 	*conf = c
-	return nil, e.Err()
+	return nil, nil
 }
 func (LoadAction) Additions(*Config) map[string]Action { return nil }
 func (LoadAction) Removals() []string                  { return nil }
 func (LoadAction) Name() string                        { return "load" }
 func (LoadAction) Desc() string                        { return "load from a file" }
 func (LoadAction) Tags() []string                      { return []string{"default"} }
+func (LoadAction) Meta() Meta                          { return Meta{Usage: "load <file>"} }
+
+// Complete completes the file to load
+func (LoadAction) Complete(_ *Config, args []string) []string {
+	if len(args) != 1 {
+		return nil
+	}
+	return CompletePath(args[0])
+}
 
 type HelpAction struct {
 	cmds *Commands
 }
 
+// NewHelpAction returns a help action that lists the actions known to cmds,
+// and prints them to cmds' IO
+func NewHelpAction(cmds *Commands) HelpAction { return HelpAction{cmds: cmds} }
+
 func (HelpAction) Payload(conf *Config) (_ interface{}, _ error) { return }
 func (s HelpAction) Execute(conf *Config, _ interface{}) (interface{}, error) {
-	instructions := "\nplease type a command: \n%s"
-	commands := func() (out string) {
-		for _, v := range s.cmds.KnownCommands() {
-			out += "\t" + v + "\n"
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "\nplease type a command, describe <command> tells more about one:\n")
+	snap := s.cmds.Snapshot()
+	tags, sections := helpSections(snap)
+	for _, t := range tags {
+		fmt.Fprintf(w, "%s:\n", t)
+		for _, a := range sections[t] {
+			name := a.Name()
+			if aliases := snap.Aliases(name); len(aliases) > 1 {
+				name += " (" + strings.Join(aliases, ", ") + ")"
+			}
+			fmt.Fprintf(w, "\t%s\t%s\n", name, a.Desc())
 		}
-		return
-	}()
-	fmt.Printf(instructions, commands)
+	}
+	w.Flush()
+	s.cmds.IO().Printf("%s", buf.String())
 
 	return nil, nil
 }
 func (HelpAction) Additions(*Config) map[string]Action { return nil }
 func (HelpAction) Removals() []string                  { return nil }
 func (HelpAction) Name() string                        { return "help" }
-func (HelpAction) Desc() string                        { return "get help for stuff" }
+func (HelpAction) Desc() string                        { return "list the commands by tag" }
 func (HelpAction) Tags() []string                      { return []string{"default"} }
+func (HelpAction) ReadOnly() bool                      { return true }
 
-type SaveAction struct{}
+// SaveAction saves the Config to a file.
+// Codec encodes the file, JSONCodec is used when it is nil.
+type SaveAction struct {
+	Codec Codec
+}
 
-func (s SaveAction) Payload(c *Config) (interface{}, error) {
+func (s SaveAction) Payload(c *Config) (interface{}, error) { return s.IOPayload(c, StdIO) }
+func (s SaveAction) IOPayload(c *Config, io *IO) (interface{}, error) {
 	var filename string
 
-	return filename, scan("type save path, or leave empty for default.", &filename)
+	err := io.Scan("type save path, or leave empty for default.", &filename)
+	return filename, err
+}
+
+// ArgsPayload uses the first argument as the save path
+func (s SaveAction) ArgsPayload(c *Config, args []string) (interface{}, error) {
+	if len(args) == 0 {
+		return nil, NoArgs
+	}
+	return args[0], nil
 }
 
 // must Always have a string payload that is the filepath to save
@@ -415,21 +734,33 @@ func (s SaveAction) Execute(c *Config, payload interface{}) (interface{}, error)
 	if !ok {
 		return nil, fmt.Errorf("payload was not a string: %v", payload)
 	}
-	ReplaceDotSlash(&ans)
-	ReplaceHome(&ans)
+	if err := expandPath(&ans); err != nil {
+		return nil, err
+	}
 
-	bytes, err := json.Marshal(c)
+	bytes, err := codecOr(s.Codec).Marshal(*c)
 	if err != nil {
 		return nil, err
 	}
-	return ans, ioutil.WriteFile(ans, bytes, 0644)
+	return ans, WriteFileAtomic(ans, bytes, 0644)
 }
 func (SaveAction) Additions(*Config) map[string]Action { return nil }
 func (SaveAction) Removals() []string                  { return nil }
 func (SaveAction) Name() string                        { return "save" }
 func (SaveAction) Desc() string                        { return "save the config to a file" }
 func (SaveAction) Tags() []string                      { return []string{"default"} }
+func (SaveAction) ReadOnly() bool                      { return true }
+func (SaveAction) Meta() Meta                          { return Meta{Usage: "save <file>"} }
 
+// Complete completes the save path
+func (SaveAction) Complete(_ *Config, args []string) []string {
+	if len(args) != 1 {
+		return nil
+	}
+	return CompletePath(args[0])
+}
+
+// WrapNameAction is an action under another name, with its payloads, and whether it is read only
 type WrapNameAction struct {
 	newName   string
 	oldAction Action
@@ -442,6 +773,25 @@ func (s WrapNameAction) New(name string, action Action) WrapNameAction {
 	return s
 }
 func (s WrapNameAction) Payload(c *Config) (interface{}, error) { return s.oldAction.Payload(c) }
+func (s WrapNameAction) IOPayload(c *Config, io *IO) (interface{}, error) {
+	return payloadWith(s.oldAction, c, io)
+}
+func (s WrapNameAction) ArgsPayload(c *Config, args []string) (interface{}, error) {
+	if p, ok := s.oldAction.(ArgsPayloader); ok {
+		return p.ArgsPayload(c, args)
+	}
+	return nil, NoArgs
+}
+func (s WrapNameAction) IOArgsPayload(c *Config, io *IO, args []string) (interface{}, error) {
+	if p, ok := s.oldAction.(IOArgsPayloader); ok {
+		return p.IOArgsPayload(c, io, args)
+	}
+	return s.ArgsPayload(c, args)
+}
+func (s WrapNameAction) ReadOnly() bool {
+	r, ok := s.oldAction.(ExecuteReadOnly)
+	return ok && r.ReadOnly()
+}
 func (s WrapNameAction) Execute(conf *Config, payload interface{}) (interface{}, error) {
 	return s.oldAction.Execute(conf, payload)
 }
@@ -450,25 +800,34 @@ func (s WrapNameAction) Additions(c *Config) map[string]Action { return s.oldAct
 func (s WrapNameAction) Removals() []string                    { return s.oldAction.Removals() }
 func (s WrapNameAction) Name() string                          { return s.newName }
 func (s WrapNameAction) Desc() string                          { return s.oldAction.Desc() }
-func (s WrapNameAction) Tags() []string                        { return append(s.Tags(), s.newName) }
+func (s WrapNameAction) Tags() []string                        { return append(s.oldAction.Tags(), s.newName) }
 
 // executes the child actions payload once, then
 // every <tick> seconds till Commands.Get("stop-" + <name>) is called,
-// Watch calls the child action's Execute function with that payload
+// Watch queues the child action's Execute function with that payload
+// Commands.Shutdown stops every watch.
 type WatchAction struct {
-	cmds   Commands
+	cmds   *Commands
 	action Action
+	tick   time.Duration
+	// guards ticker, and closing stop
+	mu     *sync.Mutex
 	ticker *time.Ticker
+	// closed by Stop
+	stop chan struct{}
 }
 
-func NewWatchAction(action Action, tick time.Duration, cmds Commands) WatchAction {
-	return WatchAction{}.New(action, tick, cmds)
+func NewWatchAction(action Action, tick time.Duration, cmds *Commands) *WatchAction {
+	w := WatchAction{}.New(action, tick, cmds)
+	return &w
 }
 
-func (s WatchAction) New(action Action, tick time.Duration, cmds Commands) WatchAction {
+func (s WatchAction) New(action Action, tick time.Duration, cmds *Commands) WatchAction {
 	s.action = action
-	s.ticker = time.NewTicker(tick)
+	s.tick = tick
 	s.cmds = cmds
+	s.stop = make(chan struct{})
+	s.mu = &sync.Mutex{}
 
 	return s
 }
@@ -476,29 +835,74 @@ func (s WatchAction) New(action Action, tick time.Duration, cmds Commands) Watch
 func (w *WatchAction) Payload(conf *Config) (interface{}, error) {
 	return w.action.Payload(conf)
 }
+func (w *WatchAction) IOPayload(conf *Config, io *IO) (interface{}, error) {
+	return payloadWith(w.action, conf, io)
+}
 
+// Execute starts the watch, a watch can only be started once, and not after it is stopped
 func (w *WatchAction) Execute(conf *Config, payload interface{}) (interface{}, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	select {
+	case <-w.stop:
+		return nil, fmt.Errorf("the watch of %s is stopped", w.action.Name())
+	default:
+	}
+	if w.ticker != nil {
+		return nil, fmt.Errorf("%s is already watched", w.action.Name())
+	}
+	w.ticker = time.NewTicker(w.tick)
+	w.cmds.watch(w)
+	ticks := w.ticker.C
 	go func() {
-		<-w.ticker.C
-		w.action.Execute(conf, payload)
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticks:
+				// dispatched like any other work, so it is journaled, snapshotted for undo, and autosaved
+				if _, _, err := w.cmds.dispatch(w.action, payload, nil); err != nil {
+					w.Stop()
+					return
+				}
+			}
+		}
 	}()
 	return nil, nil
 }
+
+// Stop stops the watch, it is safe to call more than once
+func (w *WatchAction) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	select {
+	case <-w.stop:
+		return
+	default:
+	}
+	close(w.stop)
+	if w.ticker != nil {
+		w.ticker.Stop()
+	}
+	w.cmds.unwatch(w)
+}
 func (w *WatchAction) Additions(*Config) map[string]Action {
 	return map[string]Action{
-		"stop-" + w.action.Name(): Build().WithVoidExecuteVoid(func(c *Config) error {
-			w.ticker.Stop()
+		"stop-" + w.action.Name(): Build().WithNameV("stop-" + w.action.Name()).WithDescV("stop watching " + w.action.Name()).WithVoidExecuteVoid(func(c *Config) error {
+			w.Stop()
 			return nil
 		}),
 	}
 }
 func (w *WatchAction) Removals() []string { return w.action.Removals() }
 func (w *WatchAction) Name() string       { return w.action.Name() }
+func (w *WatchAction) Desc() string       { return "watch " + w.action.Name() + " every " + w.tick.String() }
 func (w *WatchAction) Tags() []string     { return []string{"watch", "repeating", w.action.Name()} }
 
-func PrintAction(name, msg string) Action {
+// PrintAction returns an action that prints msg to io, usually the IO of the Commands it is set on
+func PrintAction(io *IO, name, msg string) Action {
 	return Build().WithNameV(name).WithVoidExecuteVoid(func(*Config) error {
-		fmt.Println(msg)
+		io.Println(msg)
 		return nil
 	})
 }
@@ -511,11 +915,11 @@ func MakeTrigger(parent Action, children ...Action) Action {
 	return Override(parent).WithAdditionsV(m)
 }
 
-// return a Payload function that asks the user to pick between the keys in the map
+// return a Payload function that asks the user to pick between the keys in the map using io
 // it performs the named PayloadFunction if it exists, and returns its result as the payload
 // unknown keys result in an error
-func ForkPayloads(payloads map[string]Payload) Payload {
-	return func(c *Config) (interface{}, error) {
+func ForkPayloads(payloads map[string]Payload) IOPayload {
+	return func(c *Config, io *IO) (interface{}, error) {
 		temp := ""
 		keys := make([]string, 0)
 		for k, _ := range payloads {
@@ -524,7 +928,7 @@ func ForkPayloads(payloads map[string]Payload) Payload {
 		}
 		var payloadF Payload
 		err := retry(3, func() error {
-			if err := scan("please pick between:"+strings.Join(keys, "\n\t"), &temp); err != nil {
+			if err := io.Scan("please pick between:\n\t"+strings.Join(keys, "\n\t"), &temp); err != nil {
 				return err
 			}
 			f, ok := payloads[temp]
@@ -0,0 +1,173 @@
+package commander
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// Autosave persists the Config to a file as it changes, see Commands.EnableAutosave
+type Autosave struct {
+	// the file the Config is saved to
+	Path string
+	// encodes and decodes the file, JSONCodec when nil
+	Codec Codec
+	// the number of previous saves to keep, as Path.1 (the most recent) to Path.N
+	Backups int
+	// when positive, the Config is saved this often if it changed, instead of after every work that changes it
+	Interval time.Duration
+	// restore the Config from the most recent save that decodes before saving anything
+	Restore bool
+}
+
+// files returns Path, then its backups from the most recent
+func (a Autosave) files() []string {
+	out := []string{a.Path}
+	for i := 1; i <= a.Backups; i++ {
+		out = append(out, a.backup(i))
+	}
+	return out
+}
+
+func (a Autosave) backup(i int) string { return fmt.Sprintf("%s.%d", a.Path, i) }
+
+// autosaver saves the Config of a Commands for an Autosave
+type autosaver struct {
+	Autosave
+	conf *Config
+	wc   *workChan
+	// makes the Config restored from a save, may be nil
+	factory Factory
+	// reports problems
+	warn func(string, ...interface{})
+	// serializes saves, so backups are rotated in order
+	mu sync.Mutex
+	// guards dirty, the Config changed since it was last saved
+	dmu   sync.Mutex
+	dirty bool
+	// closed to stop the interval loop, which closes done when it returns
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newAutosaver(a Autosave, conf *Config, wc *workChan, f Factory, warn func(string, ...interface{})) *autosaver {
+	s := &autosaver{Autosave: a, conf: conf, wc: wc, factory: f, warn: warn, stop: make(chan struct{}), done: make(chan struct{})}
+	if a.Interval > 0 {
+		go s.loop()
+	} else {
+		close(s.done)
+	}
+	return s
+}
+
+// restore sets the Config to the most recent save that decodes, and returns the file it came from.
+// Missing files are skipped, nothing is restored when none exist.
+// It is an error if saves exist, but none of them decode.
+func (s *autosaver) restore() (from string, err error) {
+	codec := codecOr(s.Codec)
+	s.wc.write(func() {
+		for _, f := range s.files() {
+			d, rerr := ioutil.ReadFile(f)
+			if os.IsNotExist(rerr) {
+				continue
+			}
+			if rerr == nil {
+				var c Config
+				if c, rerr = decodeConfig(codec, s.factory, d, *s.conf); rerr == nil {
+					*s.conf = c
+					from, err = f, nil
+					return
+				}
+			}
+			s.warn("could not restore the config from %s: %v\n", f, rerr)
+			if err == nil {
+				err = fmt.Errorf("no autosave of %s could be restored: %v", s.Path, rerr)
+			}
+		}
+	})
+	return
+}
+
+// changed saves conf, or marks it to be saved on the next interval.
+// It is called by work that still holds the Config, and runs alone when there is no interval.
+func (s *autosaver) changed(conf *Config) error {
+	if s.Interval > 0 {
+		s.dmu.Lock()
+		s.dirty = true
+		s.dmu.Unlock()
+		return nil
+	}
+	return s.save(conf)
+}
+
+// save rotates the backups, and writes conf to Path.
+// The caller must hold the Config, and no keyed work may be writing to it.
+func (s *autosaver) save(conf *Config) error {
+	data, err := codecOr(s.Codec).Marshal(*conf)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Backups > 0 {
+		for i := s.Backups; i > 1; i-- {
+			if err := os.Rename(s.backup(i-1), s.backup(i)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if err := os.Rename(s.Path, s.backup(1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return WriteFileAtomic(s.Path, data, 0644)
+}
+
+func (s *autosaver) loop() {
+	defer close(s.done)
+	t := time.NewTicker(s.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-t.C:
+			if err := s.flush(); err != nil {
+				s.warn("error autosaving to %s: %v\n", s.Path, err)
+			}
+		}
+	}
+}
+
+// flush saves the Config if it changed since it was last saved
+func (s *autosaver) flush() (err error) {
+	s.dmu.Lock()
+	dirty := s.dirty
+	s.dirty = false
+	s.dmu.Unlock()
+	if dirty {
+		s.wc.read(func() { err = s.save(s.conf) })
+	}
+	if err != nil {
+		// try again on the next flush
+		s.dmu.Lock()
+		s.dirty = true
+		s.dmu.Unlock()
+	}
+	return
+}
+
+// close stops the interval loop, and saves any change it has not saved yet when final is true
+func (s *autosaver) close(final bool) error {
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+	<-s.done
+	if final {
+		return s.flush()
+	}
+	return nil
+}
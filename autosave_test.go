@@ -0,0 +1,77 @@
+package commander
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// autosaved returns a Commands autosaving with a, its "inc" action counts in "n"
+func autosaved(t *testing.T, conf *Config, a Autosave) *Commands {
+	t.Helper()
+	c := quietCommands(conf)
+	if err := c.EnableAutosave(a); err != nil {
+		t.Fatal(err)
+	}
+	c.Set(Build().WithNameV("inc").WithVoidExecuteVoid(func(conf *Config) error {
+		(*conf).(map[string]int)["n"]++
+		return nil
+	}))
+	return c
+}
+
+func TestAutosaveBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conf.json")
+	var conf Config = map[string]int{}
+	c := autosaved(t, &conf, Autosave{Path: path, Backups: 2})
+	for i := 0; i < 4; i++ {
+		run(t, c, "inc")
+	}
+	c.Shutdown(context.Background())
+	for f, want := range map[string]string{path: `{"n":4}`, path + ".1": `{"n":3}`, path + ".2": `{"n":2}`} {
+		if d, err := ioutil.ReadFile(f); err != nil || string(d) != want {
+			t.Errorf("%s is %s, %v, want %s", filepath.Base(f), d, err, want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("a third backup is kept: %v", err)
+	}
+}
+
+func TestAutosaveRestore(t *testing.T) {
+	for _, v := range []struct {
+		name  string
+		files map[string]string
+		want  map[string]int
+		err   bool
+	}{
+		{name: "nothing saved", files: nil, want: map[string]int{"n": 1}},
+		{name: "latest", files: map[string]string{"": `{"n":5}`, ".1": `{"n":4}`}, want: map[string]int{"n": 5}},
+		{name: "bad latest", files: map[string]string{"": `{"n":`, ".1": `{"n":4}`}, want: map[string]int{"n": 4}},
+		{name: "missing latest", files: map[string]string{".2": `{"n":3}`}, want: map[string]int{"n": 3}},
+		{name: "all bad", files: map[string]string{"": `{`, ".1": `[`}, want: map[string]int{"n": 1}, err: true},
+	} {
+		path := filepath.Join(t.TempDir(), "conf.json")
+		for suffix, d := range v.files {
+			if err := ioutil.WriteFile(path+suffix, []byte(d), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var conf Config = map[string]int{"n": 1}
+		c := quietCommands(&conf)
+		err := c.EnableAutosave(Autosave{Path: path, Backups: 2, Restore: true})
+		c.Shutdown(context.Background())
+		if (err != nil) != v.err {
+			t.Errorf("%s: error is %v", v.name, err)
+		}
+		if !reflect.DeepEqual(conf, v.want) {
+			t.Errorf("%s: restored %v, want %v", v.name, conf, v.want)
+		}
+		if v.err && c.autosaver() != nil {
+			t.Errorf("%s: autosave is enabled", v.name)
+		}
+	}
+}
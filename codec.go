@@ -0,0 +1,137 @@
+package commander
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// Codec encodes and decodes a Config, it is used by the load and save actions
+type Codec interface {
+	Marshal(Config) ([]byte, error)
+	// Unmarshal decodes data into the Config conf points to
+	Unmarshal(data []byte, conf *Config) error
+}
+
+// JSONCodec is the Codec used when none is given, see NewCodec
+var JSONCodec = NewCodec(json.Marshal, json.Unmarshal)
+
+// NewCodec returns a Codec from a marshal and unmarshal pair, like json.Marshal and json.Unmarshal,
+// or the Marshal and Unmarshal of a yaml library.
+// When the Config holds a pointer, it is decoded into, in place.
+// A nil Config is replaced by whatever unmarshal decodes into an interface{}.
+// load and autosave only give it Configs holding a new, empty, pointer, so the Config they replace is never changed.
+func NewCodec(marshal func(interface{}) ([]byte, error), unmarshal func([]byte, interface{}) error) Codec {
+	return codec{marshal: marshal, unmarshal: unmarshal}
+}
+
+type codec struct {
+	marshal   func(interface{}) ([]byte, error)
+	unmarshal func([]byte, interface{}) error
+}
+
+func (c codec) Marshal(conf Config) ([]byte, error) { return c.marshal(conf) }
+func (c codec) Unmarshal(data []byte, conf *Config) error {
+	if *conf == nil {
+		return c.unmarshal(data, conf)
+	}
+	return c.unmarshal(data, *conf)
+}
+
+// NewTypedCodec is NewCodec, but every Unmarshal decodes into a new T,
+// which replaces the Config once it is decoded without error.
+// T is the concrete type held by the Config, value or pointer.
+func NewTypedCodec[T any](marshal func(interface{}) ([]byte, error), unmarshal func([]byte, interface{}) error) Codec {
+	return typedCodec[T]{codec{marshal: marshal, unmarshal: unmarshal}}
+}
+
+type typedCodec[T any] struct{ codec }
+
+func (typedCodec[T]) replaces() {}
+
+func (c typedCodec[T]) Unmarshal(data []byte, conf *Config) error {
+	var t T
+	if err := c.unmarshal(data, &t); err != nil {
+		return err
+	}
+	*conf = t
+	return nil
+}
+
+// Factory makes the new values a Config is decoded into by load and autosave,
+// so the Config keeps its concrete type, and is only replaced once the decoding succeeds.
+// See NewFactory, and Commands.SetFactory.
+type Factory interface {
+	// New returns a pointer to a new, empty, value to decode into
+	New() interface{}
+	// Config returns what the Config holds once the value New returned is decoded
+	Config(decoded interface{}) Config
+}
+
+// NewFactory returns the Factory of Configs holding a T, T can be a value or a pointer
+func NewFactory[T any]() Factory { return factory[T]{} }
+
+type factory[T any] struct{}
+
+func (factory[T]) New() interface{} { return new(T) }
+func (factory[T]) Config(decoded interface{}) Config {
+	if p, ok := decoded.(*T); ok {
+		return *p
+	}
+	// a codec replaced the value, like a typed codec does
+	return decoded
+}
+
+// replacer is implemented by codecs that replace the Config with a new value, instead of decoding into it
+type replacer interface{ replaces() }
+
+// decodeConfig returns data decoded by codec into a new Config, conf is not changed, even when decoding fails.
+// With a Factory, the new Config is a value it makes. Without one, a nil conf is decoded into an interface{},
+// and otherwise into a new, empty, value of the type conf holds.
+func decodeConfig(codec Codec, f Factory, data []byte, conf Config) (Config, error) {
+	if f == nil {
+		if _, ok := codec.(replacer); !ok && conf != nil {
+			f = sameType{reflect.TypeOf(conf)}
+		}
+	}
+	if f == nil {
+		var c Config
+		if err := codec.Unmarshal(data, &c); err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+	c := Config(f.New())
+	if err := codec.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return f.Config(c), nil
+}
+
+// sameType is the Factory of Configs holding the same type as a Config that is already held
+type sameType struct{ t reflect.Type }
+
+func (s sameType) New() interface{} {
+	if s.t.Kind() == reflect.Ptr {
+		// decoded into as it is, it is already a pointer
+		return reflect.New(s.t.Elem()).Interface()
+	}
+	return reflect.New(s.t).Interface()
+}
+func (s sameType) Config(decoded interface{}) Config {
+	if s.t.Kind() == reflect.Ptr {
+		return decoded
+	}
+	if v := reflect.ValueOf(decoded); v.Kind() == reflect.Ptr && v.Type().Elem() == s.t {
+		return v.Elem().Interface()
+	}
+	// a codec replaced the value
+	return decoded
+}
+
+// codecOr returns c, or JSONCodec if c is nil
+func codecOr(c Codec) Codec {
+	if c == nil {
+		return JSONCodec
+	}
+	return c
+}
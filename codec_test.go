@@ -0,0 +1,53 @@
+package commander
+
+import (
+	"reflect"
+	"testing"
+)
+
+type codecState struct {
+	A int
+	M map[string]int
+}
+
+func TestDecodeConfigReplaces(t *testing.T) {
+	for _, conf := range []Config{
+		&codecState{A: 7, M: map[string]int{"a": 1}},
+		codecState{A: 7, M: map[string]int{"a": 1}},
+	} {
+		c, err := decodeConfig(JSONCodec, nil, []byte(`{"M":{"b":2}}`), conf)
+		if err != nil {
+			t.Fatalf("%T: %v", conf, err)
+		}
+		want := codecState{M: map[string]int{"b": 2}}
+		if p, ok := c.(*codecState); ok {
+			if p == conf {
+				t.Errorf("decoded into the Config in place")
+			}
+			c = *p
+		}
+		if !reflect.DeepEqual(c, want) {
+			t.Errorf("%T: decoded %#v, not %#v", conf, c, want)
+		}
+	}
+}
+
+func TestDecodeConfigFailureLeavesConfig(t *testing.T) {
+	conf := &codecState{A: 7, M: map[string]int{"a": 1}}
+	if _, err := decodeConfig(JSONCodec, nil, []byte(`{"A":1,"M":{"c":0,"d":"x"}}`), conf); err == nil {
+		t.Fatal("decoded a string into an int")
+	}
+	if want := (&codecState{A: 7, M: map[string]int{"a": 1}}); !reflect.DeepEqual(conf, want) {
+		t.Errorf("the Config is %#v after a failed decode", conf)
+	}
+}
+
+func TestDecodeConfigWithFactory(t *testing.T) {
+	c, err := decodeConfig(JSONCodec, NewFactory[codecState](), []byte(`{"A":3}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := c.(codecState); !ok || s.A != 3 {
+		t.Errorf("decoded %#v", c)
+	}
+}
@@ -3,7 +3,11 @@ package commander
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 // a Map of actions that do work in order, mutating Commands.conf
@@ -11,11 +15,39 @@ import (
 // by executing the function returned from Commands.Get
 // default actions are provided, though they, as well, can be overridden
 type Commands struct {
-	opts     []opt
-	cmds     map[string]Action
+	opts []opt
+	// guards cmds, addedBy, libs, libOf, and last
+	cmdsMu sync.RWMutex
+	cmds   map[string]Action
+	// the name of the action whose additions set the action at a key
+	addedBy map[string]string
+	// the libraries in use, and the library that set the action at a key, see Use
+	libs     map[string]bool
+	libOf    map[string]string
 	workChan *workChan
 	conf     *Config
 	last     *Action
+	io       *IO
+	// receives progress and problems instead of io when not nil, see WithLogger
+	logger Logger
+	// decides what runs in place of unknown commands
+	unknown UnknownCommandHandler
+	// records the work this Commands runs, nil when there is no journal
+	jrnl *Journal
+	// snapshots of the Config for undo and redo, nil when undo is not enabled
+	undos *undoStack
+	// the default undo and redo actions, ran by Undo and Redo
+	undoAction Action
+	redoAction Action
+	// saves the Config as it changes, nil when autosave is not enabled
+	autosave *autosaver
+	// makes the Config load and autosave decode into, nil when it is not known
+	factory Factory
+	// guards watches, logger, jrnl, undos, autosave, and factory
+	mu      sync.Mutex
+	watches map[*WatchAction]struct{}
+	// the goroutines applying additions and removals of finished work
+	pending sync.WaitGroup
 }
 
 func NewCommands(c *Config, opts ...opt) *Commands {
@@ -23,36 +55,107 @@ func NewCommands(c *Config, opts ...opt) *Commands {
 	return cmds
 }
 
+// NewCommandsWithIO is NewCommands, but every prompt and print of the returned Commands,
+// and of its default actions, goes through io instead of the terminal
+func NewCommandsWithIO(c *Config, io *IO, opts ...opt) *Commands {
+	cmds := (&Commands{io: io}).New(c, opts)
+	return cmds
+}
+
 func (c *Commands) New(conf *Config, opts []opt) *Commands {
 	c.conf = conf
 	c.opts = opts
+	if c.io == nil {
+		c.io = StdIO
+	}
+	c.unknown = SuggestUnknownCommand(false)
 	c.cmds = make(map[string]Action)
-	c.workChan = newWorkChan(10)
-	c.Set(HelpAction{cmds: c})
+	c.addedBy = make(map[string]string)
+	c.libs = make(map[string]bool)
+	c.libOf = make(map[string]string)
+	c.watches = make(map[*WatchAction]struct{})
+	workers := c.optInt(OptWorkers, 1)
+	actionHistory := c.optInt(OptActionHistory, defaultActionHistory)
+	timelineHistory := c.optInt(OptTimelineHistory, defaultTimelineHistory)
+	queueSize := c.optInt(OptQueueSize, 10)
+	c.workChan = newWorkChan(int64(queueSize), workers, NewHistory(actionHistory, timelineHistory))
+	c.workChan.propagate = c.optValue(OptPropagatePanics, "false") == "true"
+	undoRedo := func(name, desc string, f func(*undoStack, *Config) (string, error)) Action {
+		return unsnapshottedAction{Build().WithNameV(name).WithTagsV("default").WithDescV(desc).
+			WithExecuteVoid(func(conf *Config) (interface{}, error) {
+				u := c.undoStack()
+				if u == nil {
+					return nil, fmt.Errorf("undo is not enabled, see Commands.EnableUndo")
+				}
+				undone, err := f(u, conf)
+				if err != nil {
+					return nil, err
+				}
+				c.io.Printf("%s: %s\n", name, undone)
+				return undone, nil
+			})}
+	}
+	c.undoAction = undoRedo("undo", "set the config back to before the last action", (*undoStack).Undo)
+	c.redoAction = undoRedo("redo", "set the config back to before the last undo", (*undoStack).Redo)
+	if c.optValue(OptDefaults, "true") != "false" {
+		c.setDefaults()
+	}
+	for _, o := range opts {
+		if o.apply != nil {
+			o.apply(c)
+		}
+	}
+
+	if libs := c.optValue(OptUse, ""); libs != "" {
+		if err := c.Use(strings.Split(libs, ",")...); err != nil {
+			c.warn("could not use %s: %v\n", libs, err)
+		}
+	}
+	if path := c.optValue(OptAutosave, ""); path != "" {
+		backups := c.optInt(OptAutosaveBackups, 0)
+		interval, _ := time.ParseDuration(c.optValue(OptAutosaveInterval, "0s"))
+		a := Autosave{Path: path, Backups: backups, Interval: interval, Restore: true}
+		if err := c.EnableAutosave(a); err != nil {
+			c.warn("autosave is not enabled: %v\n", err)
+		}
+	}
+
+	go c.workChan.Start(conf)
+
+	return c
+}
+
+// setDefaults sets the default actions, see OptDefaults
+func (c *Commands) setDefaults() {
+	c.Set(NewHelpAction(c))
 	c.Set(LoadAction{})
 	c.Set(SaveAction{})
-	c.Set(Build().WithNameV("print-config").WithExecuteVoid(func(c *Config) (interface{}, error) {
-		fmt.Println(prettyJ(c))
-		return *c, nil
-	}).WithTagsV("default"))
-	c.Set(Build().WithNameV("tags").WithVoidExecuteVoid(func(_ *Config) error {
-		fmt.Printf("Known Tags:\n\t%v\n", strings.Join(c.KnownTags(), "\n\t"))
+	c.Set(Build().WithNameV("print-config").WithDescV("print the config").WithExecuteVoid(func(conf *Config) (interface{}, error) {
+		c.io.Println(prettyJ(conf))
+		return *conf, nil
+	}).WithTagsV("default").WithReadOnly(true))
+	c.Set(Build().WithNameV("tags").WithDescV("list the known tags").WithVoidExecuteVoid(func(_ *Config) error {
+		c.io.Printf("Known Tags:\n\t%v\n", strings.Join(c.KnownTags(), "\n\t"))
 		return nil
-	}).WithTagsV("default"))
-	c.Set(Build().WithNameV("last").WithPayload(func(_ *Config) (interface{}, error) {
-		if c.last == nil {
+	}).WithTagsV("default").WithReadOnly(true))
+	c.Set(Build().WithNameV("last").WithDescV("print the last action ran, and its result").WithPayload(func(_ *Config) (interface{}, error) {
+		c.cmdsMu.RLock()
+		last := c.last
+		c.cmdsMu.RUnlock()
+		if last == nil {
 			return nil, Skip
 		}
-		return c.workChan.CachedResults[(*c.last).Name()], nil
+		return c.latest((*last).Name()), nil
 	}).WithExecute(func(_ *Config, p interface{}) (interface{}, error) {
-		fmt.Printf("\n%s\n", PrettyJson(p))
+		c.io.Printf("\n%s\n", PrettyJson(p))
 		return p, nil
-	}).WithTagsV("default"))
-	c.Set(Build().WithNameV("quit").WithPayloadV(nil, Quit).WithTagsV("default"))
-	c.Set(Build().WithNameV("lookup").WithTagsV("default").
-		WithPayload(func(*Config) (interface{}, error) {
+	}).WithTagsV("default").WithReadOnly(true))
+	c.Set(Build().WithNameV("quit").WithDescV("quit").WithPayloadV(nil, Quit).WithTagsV("default"))
+	c.Set(Build().WithNameV("lookup").WithTagsV("default").WithReadOnly(true).
+		WithDescV("print the last work of an action").WithUsage("lookup <name>").
+		WithIOPayload(func(_ *Config, io *IO) (interface{}, error) {
 			var alias string
-			if err := scan("lookup last result to which command?", &alias); err != nil {
+			if err := io.Scan("lookup last result to which command?", &alias); err != nil {
 				return nil, err
 			}
 			return alias, nil
@@ -63,17 +166,27 @@ func (c *Commands) New(conf *Config, opts []opt) *Commands {
 				return nil, fmt.Errorf("payload was not string %#v", name)
 			}
 
-			fmt.Printf("result to %s:\n %v\n", name, prettyJ(c.workChan.CachedResults[name]))
-
-			return c.workChan.CachedResults[name], nil
+			w := c.latest(name)
+			c.io.Printf("result to %s:\n %v\n", name, prettyJ(w))
+			return w, nil
+		}).
+		WithArgsPayload(func(_ *Config, args []string) (interface{}, error) {
+			return args[0], nil
+		}).
+		WithComplete(func(conf *Config, args []string) []string {
+			if len(args) != 1 {
+				return nil
+			}
+			return c.completeRan(conf, args)
 		}))
-	c.Set(Build().WithNameV("filter").WithTagsV("default").
-		WithPayload(func(*Config) (interface{}, error) {
+	c.Set(Build().WithNameV("filter").WithTagsV("default").WithReadOnly(true).
+		WithDescV("list the actions with any of the tags").WithUsage("filter <tag>...").
+		WithIOPayload(func(_ *Config, io *IO) (interface{}, error) {
 			tags := ""
-			if err := scan("enter tags to filter by separated by space:", &tags); err != nil {
+			if err := io.Scan("enter tags to filter by separated by space:", &tags); err != nil {
 				return nil, err
 			}
-			ts := strings.Split(tags, " \t")
+			ts := strings.Fields(tags)
 			return ts, nil
 		}).
 		WithExecute(func(_ *Config, payload interface{}) (interface{}, error) {
@@ -87,14 +200,23 @@ func (c *Commands) New(conf *Config, opts []opt) *Commands {
 			for _, v := range c.FilterActions(ts...) {
 				msg += "\t" + v.Name() + "\n"
 			}
-			fmt.Print(msg)
+			c.io.Printf("%s", msg)
 
 			return ts, nil
-		}))
-	c.Set(Build().WithNameV("aliases").WithTagsV("default").
-		WithPayload(func(*Config) (interface{}, error) {
+		}).
+		WithArgsPayload(func(_ *Config, args []string) (interface{}, error) {
+			return args, nil
+		}).
+		WithReplayPayload(func(_ *Config, payload interface{}) (interface{}, error) {
+			return NewKV("", "tags", LIST).Coerce(payload)
+		}).
+		WithComplete(func(_ *Config, _ []string) []string { return c.KnownTags() }))
+	c.Set(Build().WithNameV("aliases").WithTagsV("default").WithReadOnly(true).
+		WithDescV("list the names an action is known by").WithUsage("aliases <name>").
+		WithIOPayload(func(_ *Config, io *IO) (interface{}, error) {
 			var alias string
-			return alias, scan("alias to which command?", &alias)
+			err := io.Scan("alias to which command?", &alias)
+			return alias, err
 		}).
 		WithExecute(func(_ *Config, payload interface{}) (interface{}, error) {
 			ts, ok := payload.(string)
@@ -102,58 +224,578 @@ func (c *Commands) New(conf *Config, opts []opt) *Commands {
 				return nil, fmt.Errorf("payload was not string")
 			}
 
-			msg := fmt.Sprint("commands aliased to %s:\n", ts)
+			msg := fmt.Sprintf("commands aliased to %s:\n", ts)
 
 			for _, v := range c.Aliases(ts) {
 				msg += "\t" + v + "\n"
 			}
-			fmt.Print(msg)
+			c.io.Printf("%s", msg)
 
 			return ts, nil
+		}).
+		WithArgsPayload(func(_ *Config, args []string) (interface{}, error) {
+			return args[0], nil
+		}).
+		WithComplete(c.completeNames))
+
+	c.Set(Build().WithNameV("libraries").WithTagsV("default").WithReadOnly(true).
+		WithDescV("list the registered libraries of actions, and which are in use").
+		WithExecuteVoid(func(_ *Config) (interface{}, error) {
+			using := make(map[string]bool)
+			for _, v := range c.Using() {
+				using[v] = true
+			}
+			libs := Libraries()
+			for _, v := range libs {
+				if using[v] {
+					v += " (in use)"
+				}
+				c.io.Printf("\t%s\n", v)
+			}
+			return libs, nil
+		}))
+	c.Set(Build().WithNameV("describe").WithTagsV("default").WithReadOnly(true).
+		WithDescV("describe an action, its usage, arguments, and where it came from").WithUsage("describe <name>").
+		WithIOPayload(func(_ *Config, io *IO) (interface{}, error) {
+			var name string
+			err := io.Scan("describe which command?", &name)
+			return name, err
+		}).
+		WithArgsPayload(func(_ *Config, args []string) (interface{}, error) {
+			return args[0], nil
+		}).
+		WithExecute(func(_ *Config, payload interface{}) (interface{}, error) {
+			name, ok := payload.(string)
+			if !ok {
+				return nil, TypeConvertErr(payload, name)
+			}
+			d, err := c.Describe(name)
+			if err != nil {
+				return nil, err
+			}
+			c.io.Printf("%s", d)
+			return d, nil
+		}).
+		WithComplete(c.completeNames))
+	c.Set(Build().WithNameV("history").WithTagsV("default").WithReadOnly(true).WithExamples("history -n load -l 5", "history -s failure -d 1h", "history -d 2h -u 1h").
+		WithDescV("list the most recent work, of one action or of all of them").
+		WithQuestionsPayload(
+			NewKV("history of which command? (empty for all)", "name", STR).WithShort("n"),
+			NewKV("how many?", "limit", INT).WithDefault(int64(10)).WithShort("l"),
+			NewKV("which status?", "status", CHOICE).WithChoices("any", "success", "failure").WithDefault("any").WithShort("s"),
+			NewKV("how far back? (empty for all)", "since", DUR).WithShort("d"),
+			NewKV("up to how long ago? (empty for now)", "until", DUR).WithShort("u"),
+		).
+		WithExecuteMap(func(_ *Config, m map[string]interface{}) (interface{}, error) {
+			f := HistoryFilter{}
+			f.Name, _ = m["name"].(string)
+			limit, _ := m["limit"].(int64)
+			f.Limit = int(limit)
+			status, _ := m["status"].(string)
+			f.Success, f.Failure = status == "success", status == "failure"
+			now := time.Now()
+			if since, _ := m["since"].(time.Duration); since > 0 {
+				f.Since = now.Add(-since)
+			}
+			if until, _ := m["until"].(time.Duration); until > 0 {
+				f.Until = now.Add(-until)
+			}
+			ws := c.QueryHistory(f)
+			for _, w := range ws {
+				c.io.Printf("%s\t%s\t%s\n", w.CreatedAt.Format(time.RFC3339), w.Name, w.Status())
+			}
+			return ws, nil
 		}))
+	c.Set(c.undoAction)
+	c.Set(c.redoAction)
+	c.Set(Build().WithNameV("cancel").WithTagsV("default").WithReadOnly(true).WithUsage("cancel <name>...").
+		WithDescV("cancel the queued and running work of an action").
+		// canceling happens right away on the calling thread, execute only reports it
+		WithIOPayload(func(_ *Config, io *IO) (interface{}, error) {
+			var name string
+			if err := io.Scan("cancel the work of which command?", &name); err != nil {
+				return nil, err
+			}
+			return c.Cancel(name), nil
+		}).
+		WithArgsPayload(func(_ *Config, args []string) (interface{}, error) {
+			var out []*Work
+			for _, v := range args {
+				out = append(out, c.Cancel(v)...)
+			}
+			return out, nil
+		}).
+		WithExecute(func(_ *Config, payload interface{}) (interface{}, error) {
+			ws, ok := payload.([]*Work)
+			if !ok {
+				return nil, TypeConvertErr(payload, []*Work{})
+			}
+			canceled := 0
+			for _, w := range ws {
+				if w.Status() == "cancelled" {
+					canceled++
+				}
+			}
+			c.io.Printf("canceled %d work", canceled)
+			if running := len(ws) - canceled; running > 0 {
+				c.io.Printf(", asked %d running work to stop", running)
+			}
+			c.io.Printf("\n")
+			return canceled, nil
+		}).
+		WithComplete(c.completeRan))
+}
 
-	go c.workChan.Start(conf)
+// optValue returns the value of the last opt given with key, or def if there is none
+func (c *Commands) optValue(key, def string) string {
+	for i := len(c.opts) - 1; i >= 0; i-- {
+		if k, v := c.opts[i].Read(); k == key {
+			return v
+		}
+	}
+	return def
+}
 
-	return c
+// optInt returns the value of the last opt given with key as a number,
+// or def if there is none, or it is not a number
+func (c *Commands) optInt(key string, def int) int {
+	n, err := strconv.Atoi(strings.TrimSpace(c.optValue(key, "")))
+	if err != nil {
+		return def
+	}
+	return n
 }
 
+// IO returns the streams this Commands prompts and prints with
+func (c *Commands) IO() *IO { return c.io }
+
+// Set stores a at its name, and at each of additionalKeys.
+// It is safe to call while actions are running.
 func (c *Commands) Set(a Action, additionalKeys ...string) {
-	for _, v := range additionalKeys {
+	c.set(a, "", additionalKeys...)
+}
+
+// set is Set, recording the name of the action whose additions added a, if any
+func (c *Commands) set(a Action, addedBy string, additionalKeys ...string) {
+	c.cmdsMu.Lock()
+	defer c.cmdsMu.Unlock()
+	for _, v := range append([]string{a.Name()}, additionalKeys...) {
 		c.cmds[v] = a
+		delete(c.libOf, v)
+		if addedBy == "" {
+			delete(c.addedBy, v)
+		} else {
+			c.addedBy[v] = addedBy
+		}
 	}
-	c.cmds[a.Name()] = a
 }
 
 func (c *Commands) Wrap(a Action) func() (*Work, error) {
 	return c.processor(a)
 }
 
+// Remove removes the actions stored at keys.
+// It is safe to call while actions are running.
 func (c *Commands) Remove(keys ...string) {
+	c.cmdsMu.Lock()
+	defer c.cmdsMu.Unlock()
 	for _, v := range keys {
-		c.cmds[v] = nil
+		delete(c.cmds, v)
+		delete(c.addedBy, v)
+		delete(c.libOf, v)
 	}
 }
 func (c *Commands) Get(key string) func() (*Work, error) {
-	if k, ok := c.cmds[strings.TrimSpace(strings.ToLower(key))]; k != nil && ok {
-		return c.processor(k)
+	a, err := c.lookup(key)
+	if err != nil {
+		return func() (*Work, error) { return nil, err }
+	}
+	return c.processor(a)
+}
+
+// Run splits line into words with shell style quoting, and runs the action named by the first word.
+// The rest of the words are given to the action's ArgsPayload if it implements ArgsPayloader,
+// otherwise, or when there are no other words, the action's interactive payload is used.
+func (c *Commands) Run(line string) (*Work, error) {
+	args, err := SplitArgs(line)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return c.Get("")()
+	}
+	a, err := c.lookup(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return c.argsProcessor(a, args[1:])()
+}
+
+// RunWithPayload runs the action stored at name with payload, instead of asking the action for one,
+// so nothing is prompted for. Payloads decoded from json lose their types, when the action is a
+// ReplayPayloader, payload is given to its ReplayPayload first, the way Replay does.
+// The UnknownCommandHandler is not used, an unknown name is an UnknownCommandError.
+func (c *Commands) RunWithPayload(name string, payload interface{}) (*Work, error) {
+	if c.stopped() {
+		return nil, Stopped
+	}
+	a, ok := c.Snapshot()[strings.TrimSpace(strings.ToLower(name))]
+	if !ok {
+		return nil, UnknownCommandError{Key: name, Suggestions: c.Suggest(name)}
+	}
+	if r, ok := a.(ReplayPayloader); ok {
+		var err error
+		if payload, err = r.ReplayPayload(c.conf, payload); err != nil {
+			return nil, err
+		}
+	}
+	c.note("executing action %s", a.Name())
+	work, _, err := c.dispatch(a, payload, nil)
+	return work, err
+}
+
+// lookup returns the action stored at key, or what the UnknownCommandHandler gives when there is none
+func (c *Commands) lookup(key string) (Action, error) {
+	c.cmdsMu.RLock()
+	k, ok := c.cmds[strings.TrimSpace(strings.ToLower(key))]
+	c.cmdsMu.RUnlock()
+	if k != nil && ok {
+		return k, nil
+	}
+	if a := c.unknown(c, key); a != nil {
+		return a, nil
+	}
+	return nil, UnknownCommandError{Key: key, Suggestions: c.Suggest(key)}
+}
+
+// note reports the progress of work, framed in dashes on Out, or to the Logger
+func (c *Commands) note(format string, a ...interface{}) {
+	if l := c.Logger(); l != nil {
+		l.Printf(format, a...)
+		return
+	}
+	c.io.Dashes(fmt.Sprintf(format, a...))
+}
+
+// warn reports a problem that does not stop anything, on Err, or to the Logger
+func (c *Commands) warn(format string, a ...interface{}) {
+	if l := c.Logger(); l != nil {
+		l.Printf(format, a...)
+		return
+	}
+	c.io.Eprintf(format, a...)
+}
+
+// SetLogger reports the progress of work, and problems that do not stop anything, to l
+// instead of the Commands' IO, a nil l reports them to the IO again. See WithLogger.
+func (c *Commands) SetLogger(l Logger) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.logger = l
+}
+
+// Logger returns the Logger given to WithLogger or SetLogger, nil when reports go to the Commands' IO
+func (c *Commands) Logger() Logger {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.logger
+}
+
+// SetCodec sets the load and save actions to ones that read and write files with codec
+func (c *Commands) SetCodec(codec Codec) {
+	c.mu.Lock()
+	f := c.factory
+	c.mu.Unlock()
+	c.Set(LoadAction{Codec: codec, Factory: f})
+	c.Set(SaveAction{Codec: codec})
+}
+
+// SetFactory makes the Config load and autosave decode into with f, so it keeps its concrete type,
+// and is only replaced once decoding succeeds. The load action is updated to use it, if it is a LoadAction.
+// Without a Factory, the Config is decoded into a new value of the type it holds.
+func (c *Commands) SetFactory(f Factory) {
+	c.mu.Lock()
+	c.factory = f
+	c.mu.Unlock()
+	c.cmdsMu.RLock()
+	load, ok := c.cmds["load"].(LoadAction)
+	c.cmdsMu.RUnlock()
+	if ok {
+		load.Factory = f
+		c.Set(load)
+	}
+}
+
+// EnableAutosave saves the Config to a.Path after every successful work that is not read only,
+// or every a.Interval if it changed, keeping a.Backups previous saves.
+// Files are written to a temporary file first, then renamed, so a crash never leaves a half written save.
+// With a.Restore, the Config is first restored from the most recent save that decodes,
+// falling back to the backups, and autosave is not enabled if there are saves but none decode.
+// Calling it again replaces the previous autosave, an empty a.Path disables it.
+// A save reads the whole Config, so saving after every work runs keyed work alone like work without a key,
+// an interval save waits for running keyed work instead, the way read only work does.
+func (c *Commands) EnableAutosave(a Autosave) error {
+	c.stopAutosave(false)
+	if a.Path == "" {
+		return nil
+	}
+	if err := expandPath(&a.Path); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	f := c.factory
+	c.mu.Unlock()
+	s := newAutosaver(a, c.conf, c.workChan, f, c.warn)
+	if a.Restore {
+		if _, err := s.restore(); err != nil {
+			s.close(false)
+			return err
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.autosave = s
+	return nil
+}
+
+// autosaver returns the autosave of EnableAutosave, or nil
+func (c *Commands) autosaver() *autosaver {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.autosave
+}
+
+// stopAutosave disables autosave, saving any unsaved change first when final is true
+func (c *Commands) stopAutosave(final bool) error {
+	c.mu.Lock()
+	s := c.autosave
+	c.autosave = nil
+	c.mu.Unlock()
+	if s == nil {
+		return nil
+	}
+	return s.close(final)
+}
+
+// SetJournal records every work this Commands runs from now on to j. A nil j stops recording.
+func (c *Commands) SetJournal(j *Journal) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.jrnl = j
+}
+
+// journal returns the Journal given to SetJournal, or nil
+func (c *Commands) journal() *Journal {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.jrnl
+}
+
+// EnableUndo snapshots the Config with cloner before every work that is not read only,
+// keeping the most recent depth snapshots for Undo and Redo.
+// A nil cloner, or depth less than 1, disables undo and drops the snapshots.
+// The snapshot needs the whole Config, so with undo enabled, keyed work runs alone like work without a key.
+func (c *Commands) EnableUndo(cloner Cloner, depth int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cloner == nil || depth < 1 {
+		c.undos = nil
+		return
+	}
+	c.undos = &undoStack{cloner: cloner, depth: depth}
+}
+
+// undoStack returns the snapshots of EnableUndo, or nil
+func (c *Commands) undoStack() *undoStack {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.undos
+}
+
+// Undo queues work that sets the Config back to what it was before the last work ran.
+// The result of the work is the name of the action that was undone.
+func (c *Commands) Undo() (*Work, error) {
+	work, _, err := c.dispatch(c.undoAction, nil, nil)
+	return work, err
+}
+
+// Redo queues work that sets the Config back to what it was before the last Undo
+func (c *Commands) Redo() (*Work, error) {
+	work, _, err := c.dispatch(c.redoAction, nil, nil)
+	return work, err
+}
+
+// Replay runs the work recorded in a journal again, in the order it was dispatched,
+// using the recorded payloads instead of asking for new ones.
+// Each work is waited on, along with its additions and removals, before the next is ran,
+// so actions added by a trigger during the recording can be replayed.
+// Actions implementing ReplayPayloader are given the chance to restore their payload's type first.
+// Entries of canceled work are not replayed.
+// Replay stops at the first entry it can not run, and returns the work it ran.
+func (c *Commands) Replay(dec Decoder) ([]*Work, error) {
+	entries, err := ReadJournal(dec)
+	if err != nil {
+		return nil, err
+	}
+	var out []*Work
+	for _, e := range entries {
+		if c.stopped() {
+			return out, Stopped
+		}
+		if e.Cancelled {
+			continue
+		}
+		a, ok := c.Snapshot()[e.Name]
+		if !ok {
+			return out, fmt.Errorf("replay entry %d: unknown action %s", e.Seq, e.Name)
+		}
+		payload := e.Payload
+		if r, ok := a.(ReplayPayloader); ok {
+			if payload, err = r.ReplayPayload(c.conf, payload); err != nil {
+				return out, fmt.Errorf("replay entry %d: %s: %v", e.Seq, e.Name, err)
+			}
+		}
+		c.note("replaying action %s", a.Name())
+		work, applied, err := c.dispatch(a, payload, nil)
+		if err != nil {
+			return out, err
+		}
+		<-applied
+		out = append(out, work)
+	}
+	return out, nil
+}
+
+// Shutdown stops this Commands. New work is refused with Stopped, watches are stopped,
+// and the work already queued is ran till ctx is done.
+// If ctx is done first, all the queued and running work is canceled, and returned as abandoned
+// along with ctx's error. Running work that can not be canceled is not waited on.
+// Shutdown is safe to call more than once.
+func (c *Commands) Shutdown(ctx context.Context) (abandoned []*Work, err error) {
+	c.mu.Lock()
+	watches := c.watches
+	c.watches = make(map[*WatchAction]struct{})
+	c.mu.Unlock()
+	for w := range watches {
+		w.Stop()
+	}
+
+	c.workChan.Stop()
+	select {
+	case <-c.workChan.Stopped():
+	case <-ctx.Done():
+		for _, v := range c.workChan.Active("") {
+			if v.Cancel() {
+				abandoned = append(abandoned, v)
+			}
+		}
+		c.stopAutosave(false)
+		return abandoned, ctx.Err()
+	}
+
+	pending := make(chan struct{})
+	go func() {
+		c.pending.Wait()
+		close(pending)
+	}()
+	select {
+	case <-pending:
+		return nil, c.stopAutosave(true)
+	case <-ctx.Done():
+		c.stopAutosave(false)
+		return nil, ctx.Err()
+	}
+}
+
+// stopped reports whether Shutdown has been called
+func (c *Commands) stopped() bool { return c.workChan.Closed() }
+
+// watch remembers w so Shutdown can stop it
+func (c *Commands) watch(w *WatchAction) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.watches[w] = struct{}{}
+}
+
+// unwatch forgets w
+func (c *Commands) unwatch(w *WatchAction) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.watches, w)
+}
+
+// Cancel cancels all the queued and running work of the action named name, see Work.Cancel.
+// It returns the queued work that was canceled, and the running work that was asked to stop,
+// which is only Cancelled once its job returns the context's error.
+func (c *Commands) Cancel(name string) (out []*Work) {
+	if name = strings.TrimSpace(name); name == "" {
+		return nil
+	}
+	for _, v := range c.workChan.Active(name) {
+		if v.Cancel() {
+			out = append(out, v)
+		}
 	}
-	fmt.Println("\tunknown command: " + key)
-	return c.processor(c.cmds["help"])
+	return
 }
 
 func (c *Commands) LatestResult(a Action) *Work {
-	return c.workChan.CachedResults[a.Name()]
+	return c.latest(a.Name())
+}
+
+// latest returns the most recent work of the action named name, or nil
+func (c *Commands) latest(name string) *Work {
+	if ws := c.QueryHistory(HistoryFilter{Name: name, Limit: 1}); len(ws) > 0 {
+		return ws[0]
+	}
+	return nil
+}
+
+// History returns the n most recent work of the action named name, most recent first.
+// An empty name returns the most recent work of all actions.
+func (c *Commands) History(name string, n int) []*Work {
+	return c.workChan.history.Recent(name, n)
+}
+
+// QueryHistory returns the recent work matching f, most recent first
+func (c *Commands) QueryHistory(f HistoryFilter) []*Work {
+	return c.workChan.history.Query(f)
 }
 
-func (c *Commands) KnownCommands() (out []string) {
+// Snapshot is a copy of the actions known to a Commands at one point in time,
+// keyed by name and alias. It does not change when actions are set or removed.
+type Snapshot map[string]Action
+
+// Snapshot returns a copy of the actions known to this Commands.
+// Use it to see a consistent view across several lookups, while actions are being added and removed.
+func (c *Commands) Snapshot() Snapshot {
+	c.cmdsMu.RLock()
+	defer c.cmdsMu.RUnlock()
+	out := make(Snapshot, len(c.cmds))
 	for k, v := range c.cmds {
 		if v != nil {
-			out = append(out, k)
+			out[k] = v
 		}
 	}
+	return out
+}
+
+func (c *Commands) KnownCommands() []string               { return c.Snapshot().KnownCommands() }
+func (c *Commands) FilterActions(tags ...string) []Action { return c.Snapshot().FilterActions(tags...) }
+func (c *Commands) Aliases(name string) []string          { return c.Snapshot().Aliases(name) }
+func (c *Commands) KnownTags() []string                   { return c.Snapshot().KnownTags() }
+
+// KnownCommands returns every name and alias in s, sorted
+func (s Snapshot) KnownCommands() (out []string) {
+	for k := range s {
+		out = append(out, k)
+	}
+	sort.Strings(out)
 	return
 }
-func (c *Commands) FilterActions(tags ...string) (out []Action) {
+
+// FilterActions returns the actions in s with at least one of tags
+func (s Snapshot) FilterActions(tags ...string) (out []Action) {
 	ts := make(map[string]bool)
 	for _, v := range tags {
 		ts[v] = true
@@ -166,24 +808,33 @@ func (c *Commands) FilterActions(tags ...string) (out []Action) {
 		}
 		return false
 	}
-	for _, v := range c.cmds {
+	for _, v := range s {
 		if atLeastOneMatch(v.Tags()) {
 			out = append(out, v)
 		}
 	}
 	return
 }
-func (c *Commands) Aliases(name string) (out []string) {
-	for k, v := range c.cmds {
-		if v.Name() == c.cmds[name].Name() {
+
+// Aliases returns every key in s storing an action with the same name as the action stored at name, sorted
+func (s Snapshot) Aliases(name string) (out []string) {
+	a, ok := s[name]
+	if !ok {
+		return nil
+	}
+	for k, v := range s {
+		if v.Name() == a.Name() {
 			out = append(out, k)
 		}
 	}
+	sort.Strings(out)
 	return
 }
-func (c *Commands) KnownTags() (out []string) {
+
+// KnownTags returns every tag of the actions in s, sorted
+func (s Snapshot) KnownTags() (out []string) {
 	o := make(map[string]bool)
-	for _, i := range c.cmds {
+	for _, i := range s {
 		for _, j := range i.Tags() {
 			o[j] = true
 		}
@@ -191,10 +842,48 @@ func (c *Commands) KnownTags() (out []string) {
 	for k := range o {
 		out = append(out, k)
 	}
+	sort.Strings(out)
 	return
 }
 
+// payload returns the result of a's ArgsPayload when there are args, and a's interactive payload otherwise
+func (c *Commands) payload(a Action, args []string) (interface{}, error) {
+	if len(args) > 0 {
+		var payload interface{}
+		err := error(NoArgs)
+		if p, ok := a.(IOArgsPayloader); ok {
+			payload, err = p.IOArgsPayload(c.conf, c.io, args)
+		} else if p, ok := a.(ArgsPayloader); ok {
+			payload, err = p.ArgsPayload(c.conf, args)
+		}
+		if _, ok := err.(NoArgsError); !ok {
+			return payload, err
+		}
+		c.warn("%s does not take arguments, ignoring: %v\n", a.Name(), args)
+	}
+	return payloadWith(a, c.conf, c.io)
+}
+
 func (c *Commands) processor(a Action) func() (*Work, error) {
+	return c.argsProcessor(a, nil)
+}
+
+func (c *Commands) argsProcessor(a Action, args []string) func() (*Work, error) {
+	return func() (*Work, error) {
+		if c.stopped() {
+			return nil, Stopped
+		}
+		c.note("executing action %s", a.Name())
+
+		payload, err := c.payload(a, args)
+		work, _, err := c.dispatch(a, payload, err)
+		return work, err
+	}
+}
+
+// dispatch turns a's payload into work, and runs it.
+// The returned channel is closed once the work is done, and a's additions and removals are applied.
+func (c *Commands) dispatch(a Action, payload interface{}, err error) (*Work, <-chan struct{}, error) {
 	setLast := func() bool {
 		for _, v := range a.Tags() {
 			if v == "default" {
@@ -203,36 +892,90 @@ func (c *Commands) processor(a Action) func() (*Work, error) {
 		}
 		return true
 	}
-	// the exact same as A, but with a No-op execute func
-	skipA := Override(a).WithExecute(NopParts().Execute())
-	return func() (*Work, error) {
-		dashes(fmt.Sprintf("executing action %s", a.Name()))
-
-		payload, err := a.Payload(c.conf)
-		var work *Work
-		if _, ok := err.(SkipExecute); ok {
-			fmt.Println("skipping execution function")
-			work = workFromAction(skipA, payload)
-			close(work.wait)
-		} else if err != nil {
-			return nil, err
-		} else {
-			work = workFromAction(a, payload)
-			c.workChan.Queue(work)
+	var seq int64
+	var work *Work
+	// counted while the queue can not be closed, so Shutdown waits for the additions of all accepted work
+	accepted := func() { c.pending.Add(1) }
+	if _, ok := err.(SkipExecute); ok {
+		c.note("skipping execution of %s", a.Name())
+		// the exact same as A, but with a No-op execute func
+		skipA := Override(a).WithExecute(NopParts().Execute())
+		work = workFromAction(skipA, payload)
+		if err := c.workChan.Accept(accepted); err != nil {
+			return nil, nil, err
 		}
-		if setLast() {
-			c.last = &a
+		work.skip()
+	} else if err != nil {
+		return nil, nil, err
+	} else if r, ok := a.(ExecuteReadOnly); ok && r.ReadOnly() {
+		work = workFromAction(a, payload)
+		seq = c.journal().next()
+		if err := c.workChan.ReadOnly(c.conf, work, accepted); err != nil {
+			return nil, nil, err
 		}
-		go func() {
-			if err := work.Wait(context.Background()); err == nil {
-				for k, v := range a.Additions(c.conf) {
-					c.Set(v, k)
+	} else {
+		work = workFromAction(a, payload)
+		// the snapshot taken before the job, kept once it succeeds
+		var keep func()
+		if _, ok := a.(unsnapshotted); !ok {
+			if u := c.undoStack(); u != nil {
+				// the snapshot reads all of the Config, other keyed work must not be writing to it
+				work.alone = true
+				work.before = func(conf *Config) {
+					var err error
+					if keep, err = u.save(work.Name, conf); err != nil {
+						c.warn("error saving a snapshot before %s: %v\n", work.Name, err)
+					}
 				}
-				c.Remove(a.Removals()...)
 			}
-			dashes(fmt.Sprintf("finished action %s\n, examine it with lookup result", a.Name()))
-
-		}()
-		return work, nil
+		}
+		s := c.autosaver()
+		if s != nil && s.Interval <= 0 {
+			// the save reads all of the Config, other keyed work must not be writing to it
+			work.alone = true
+		}
+		work.after = func(conf *Config) {
+			if keep != nil {
+				keep()
+			}
+			if s == nil {
+				return
+			}
+			if err := s.changed(conf); err != nil {
+				c.warn("error autosaving after %s: %v\n", work.Name, err)
+			}
+		}
+		seq = c.journal().next()
+		if err := c.workChan.Queue(work, accepted); err != nil {
+			return nil, nil, err
+		}
 	}
+	if setLast() {
+		c.cmdsMu.Lock()
+		c.last = &a
+		c.cmdsMu.Unlock()
+	}
+	applied := make(chan struct{})
+	work.applied = applied
+	go func() {
+		defer c.pending.Done()
+		defer close(applied)
+		if err := work.Wait(context.Background()); err == nil && !work.Cancelled {
+			// the Config is held for reading, later work may already be changing it
+			var additions map[string]Action
+			c.workChan.read(func() { additions = a.Additions(c.conf) })
+			for k, v := range additions {
+				c.set(v, a.Name(), k)
+			}
+			c.Remove(a.Removals()...)
+		}
+		if seq > 0 {
+			if err := c.journal().Record(seq, work); err != nil {
+				c.warn("error writing %s to the journal: %v\n", work.Name, err)
+			}
+		}
+		c.note("finished action %s\n, examine it with lookup result", a.Name())
+
+	}()
+	return work, applied, nil
 }
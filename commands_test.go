@@ -0,0 +1,490 @@
+package commander
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func quietCommands(conf *Config, opts ...opt) *Commands {
+	return NewCommandsWithIO(conf, NewIO(nil, ioutil.Discard, ioutil.Discard), opts...)
+}
+
+// run runs line on c, and waits for its work, it can be called from any goroutine
+func run(t *testing.T, c *Commands, line string) *Work {
+	t.Helper()
+	w, err := c.Run(line)
+	if err != nil {
+		t.Error(err)
+		return nil
+	}
+	if err := w.Wait(context.Background()); err != nil {
+		t.Error(err)
+	}
+	return w
+}
+
+// run with -race, the registry is changed by callers and by finished work at the same time
+func TestRegistryConcurrentUse(t *testing.T) {
+	var conf Config = map[string]int{}
+	c := quietCommands(&conf, WithWorkers(4))
+	defer c.Shutdown(context.Background())
+
+	// keyed work runs on several workers, each adds and removes actions once it is done
+	for i := 0; i < 4; i++ {
+		key := fmt.Sprintf("k%d", i)
+		c.Set(Build().WithNameV("add-" + key).WithConcurrencyKey(key).
+			WithAdditions(func(*Config) map[string]Action {
+				return map[string]Action{"added-" + key: Build().WithNameV("added-" + key).WithTagsV(key)}
+			}))
+		c.Set(Build().WithNameV("remove-" + key).WithConcurrencyKey(key).WithRemovalsV("added-" + key))
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		key := fmt.Sprintf("k%d", i)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				run(t, c, "add-"+key)
+				run(t, c, "remove-"+key)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				c.Set(Build().WithNameV("set-"+key), "alias-"+key)
+				snap := c.Snapshot()
+				snap.KnownCommands()
+				snap.KnownTags()
+				c.FilterActions(key)
+				c.Aliases("set-" + key)
+				c.Remove("set-"+key, "alias-"+key)
+			}
+		}()
+	}
+	wg.Wait()
+
+	snap := c.Snapshot()
+	for i := 0; i < 4; i++ {
+		for _, k := range []string{"set-k%d", "alias-k%d"} {
+			if _, ok := snap[fmt.Sprintf(k, i)]; ok {
+				t.Errorf("%s is still set", fmt.Sprintf(k, i))
+			}
+		}
+	}
+}
+
+// run with -race, read only actions print work that keyed work is still finishing
+func TestReadOnlyLookupOfRunningWork(t *testing.T) {
+	var conf Config = map[string]int{}
+	c := quietCommands(&conf, WithWorkers(2))
+	defer c.Shutdown(context.Background())
+	c.Set(Build().WithNameV("k").WithConcurrencyKey("k").WithExecuteVoid(func(*Config) (interface{}, error) {
+		time.Sleep(time.Millisecond)
+		return "done", nil
+	}))
+	for i := 0; i < 50; i++ {
+		if _, err := c.Run("k"); err != nil {
+			t.Fatal(err)
+		}
+		run(t, c, "lookup k")
+		run(t, c, "last")
+	}
+}
+
+func TestNumericOpts(t *testing.T) {
+	var conf Config = map[string]int{}
+	for _, tc := range []struct {
+		opts           []opt
+		queue, workers int
+	}{
+		{[]opt{WithQueueSize(-1), WithWorkers(-3)}, 1, 1},
+		{[]opt{Opt(OptQueueSize, "abc"), Opt(OptWorkers, "many")}, 10, 1},
+		{[]opt{Opt(OptQueueSize, " 4 "), WithWorkers(2)}, 4, 2},
+		{[]opt{Opt(OptActionHistory, "-1"), Opt(OptTimelineHistory, "lots")}, 10, 1},
+	} {
+		c := quietCommands(&conf, tc.opts...)
+		if got := c.workChan.size; got != tc.queue {
+			t.Errorf("%v: queue size is %d, not %d", tc.opts, got, tc.queue)
+		}
+		if got := cap(c.workChan.workers); got != tc.workers {
+			t.Errorf("%v: workers are %d, not %d", tc.opts, got, tc.workers)
+		}
+		if h := c.workChan.history; h.size != defaultActionHistory || len(h.timeline.buf) != defaultTimelineHistory {
+			t.Errorf("%v: history keeps %d and %d", tc.opts, h.size, len(h.timeline.buf))
+		}
+		c.Set(Build().WithNameV("noop"))
+		run(t, c, "noop")
+		c.Shutdown(context.Background())
+	}
+}
+
+// run with -race, work is dispatched while the Commands shuts down
+func TestShutdownWhileDispatching(t *testing.T) {
+	var conf Config = map[string]int{}
+	c := quietCommands(&conf, WithWorkers(2))
+	c.Set(Build().WithNameV("k").WithConcurrencyKey("k"))
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if _, err := c.Run("k"); err != nil {
+					return
+				}
+				if _, err := c.Run("tags"); err != nil {
+					return
+				}
+			}
+		}()
+	}
+	time.Sleep(time.Millisecond)
+	if _, err := c.Shutdown(context.Background()); err != nil {
+		t.Error(err)
+	}
+	wg.Wait()
+}
+
+// run with -race, read only work reads the Config while keyed work writes to it
+func TestReadOnlyWaitsOnKeyedWork(t *testing.T) {
+	var conf Config = map[string]int{}
+	c := quietCommands(&conf, WithWorkers(2))
+	defer c.Shutdown(context.Background())
+	for _, k := range []string{"a", "b"} {
+		k := k
+		c.Set(Build().WithNameV("inc-" + k).WithConcurrencyKey(k).WithVoidExecuteVoid(func(conf *Config) error {
+			(*conf).(map[string]int)[k]++
+			return nil
+		}))
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			run(t, c, "print-config")
+		}
+	}()
+	var works []*Work
+	for i := 0; i < 50; i++ {
+		for _, k := range []string{"a", "b"} {
+			w, err := c.Run("inc-" + k)
+			if err != nil {
+				t.Fatal(err)
+			}
+			works = append(works, w)
+		}
+	}
+	for _, w := range works {
+		w.Wait(context.Background())
+	}
+	wg.Wait()
+	if m := conf.(map[string]int); m["a"] != 50 || m["b"] != 50 {
+		t.Errorf("config is %v", m)
+	}
+}
+
+func TestGateTakesTurns(t *testing.T) {
+	var g gate
+	g.enter(readers)
+	entered := make(chan int, 2)
+	go func() {
+		g.enter(writers)
+		entered <- writers
+		g.leave(writers)
+	}()
+	// wait for the writer to wait, later readers then wait on it
+	for {
+		g.mu.Lock()
+		waiting := g.waiting[writers]
+		g.mu.Unlock()
+		if waiting == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	go func() {
+		g.enter(readers)
+		entered <- readers
+		g.leave(readers)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	g.leave(readers)
+	if first, second := <-entered, <-entered; first != writers || second != readers {
+		t.Errorf("entered %d then %d", first, second)
+	}
+}
+
+// run with -race, undo snapshots the whole Config before keyed work
+func TestUndoWithKeyedWork(t *testing.T) {
+	var conf Config = map[string]int{}
+	c := quietCommands(&conf, WithWorkers(2), WithUndo(JSONCloner[map[string]int](), 200))
+	defer c.Shutdown(context.Background())
+	for _, k := range []string{"a", "b"} {
+		k := k
+		c.Set(Build().WithNameV("inc-" + k).WithConcurrencyKey(k).WithVoidExecuteVoid(func(conf *Config) error {
+			(*conf).(map[string]int)[k]++
+			return nil
+		}))
+	}
+	var works []*Work
+	for i := 0; i < 20; i++ {
+		for _, k := range []string{"a", "b"} {
+			w, err := c.Run("inc-" + k)
+			if err != nil {
+				t.Fatal(err)
+			}
+			works = append(works, w)
+		}
+	}
+	for _, w := range works {
+		w.Wait(context.Background())
+	}
+	run(t, c, "undo")
+	if m := conf.(map[string]int); m["a"]+m["b"] != 39 {
+		t.Errorf("config is %v after undo", m)
+	}
+}
+
+// run with -race, autosave saves the whole Config after, or between, keyed work
+func TestAutosaveWithKeyedWork(t *testing.T) {
+	for _, interval := range []time.Duration{0, time.Millisecond} {
+		var conf Config = map[string]int{}
+		path := filepath.Join(t.TempDir(), "conf.json")
+		c := quietCommands(&conf, WithWorkers(2), WithAutosave(Autosave{Path: path, Interval: interval}))
+		for _, k := range []string{"a", "b"} {
+			k := k
+			c.Set(Build().WithNameV("inc-" + k).WithConcurrencyKey(k).WithVoidExecuteVoid(func(conf *Config) error {
+				(*conf).(map[string]int)[k]++
+				return nil
+			}))
+		}
+		for i := 0; i < 20; i++ {
+			for _, k := range []string{"a", "b"} {
+				if _, err := c.Run("inc-" + k); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		if _, err := c.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		d, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(d) != `{"a":20,"b":20}` {
+			t.Errorf("saved %s with interval %v", d, interval)
+		}
+	}
+}
+
+func TestShutdownWithFullQueue(t *testing.T) {
+	var conf Config = map[string]int{}
+	c := quietCommands(&conf, WithQueueSize(1))
+	release := make(chan struct{})
+	defer close(release)
+	c.Set(Build().WithNameV("block").WithVoidExecuteVoid(func(*Config) error {
+		<-release
+		return nil
+	}))
+	running, err := c.Run("block")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for running.Status() != "running" {
+		time.Sleep(time.Millisecond)
+	}
+	queued, err := c.Run("block")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the queue is full, so this waits for room till Shutdown
+	blocked := make(chan error, 1)
+	go func() {
+		_, err := c.Run("block")
+		blocked <- err
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	abandoned, err := c.Shutdown(ctx)
+	if err != context.DeadlineExceeded || time.Since(start) > time.Second {
+		t.Errorf("shutdown returned %v after %v", err, time.Since(start))
+	}
+	if err := <-blocked; err != Stopped {
+		t.Errorf("queueing on a full queue returned %v", err)
+	}
+	if len(abandoned) != 2 || queued.Status() != "cancelled" {
+		t.Errorf("abandoned %d work, the queued work is %s", len(abandoned), queued.Status())
+	}
+}
+
+// run with -race, a watch is stopped while it starts
+func TestWatchStopWhileStarting(t *testing.T) {
+	var conf Config = map[string]int{}
+	c := quietCommands(&conf)
+	w := NewWatchAction(Build().WithNameV("tick"), time.Millisecond, c)
+	c.Set(w)
+	work, err := c.Run("tick")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go w.Stop()
+	work.Wait(context.Background())
+	w.Stop()
+	if _, err := w.Execute(&conf, nil); err == nil {
+		t.Error("a stopped watch started again")
+	}
+	c.mu.Lock()
+	watches := len(c.watches)
+	c.mu.Unlock()
+	if watches != 0 {
+		t.Errorf("%d watches are kept after stopping", watches)
+	}
+	c.Shutdown(context.Background())
+}
+
+// the wrapped payloads ask through the Commands' IO, and keep the action's arguments, and read only
+func TestWrappedActionPayloads(t *testing.T) {
+	var conf Config = map[string]int{}
+	c := NewCommandsWithIO(&conf, NewIO(strings.NewReader("typed\ntyped\n"), ioutil.Discard, ioutil.Discard))
+	defer c.Shutdown(context.Background())
+	ask := Build().WithNameV("ask").WithReadOnly(true).
+		WithIOPayload(func(_ *Config, io *IO) (interface{}, error) {
+			line, err := io.In().ReadString('\n')
+			return strings.TrimSpace(line), err
+		}).
+		WithArgsPayload(func(_ *Config, args []string) (interface{}, error) { return args[0], nil }).
+		WithExecute(func(_ *Config, p interface{}) (interface{}, error) { return p, nil })
+	wrapped := WrapNameAction{}.New("asked", ask)
+	c.Set(wrapped)
+	for line, want := range map[string]string{"asked": "typed", "asked given": "given"} {
+		if res, err := run(t, c, line).Res(); err != nil || res != want {
+			t.Errorf("%s is %v, %v", line, res, err)
+		}
+	}
+	if !wrapped.ReadOnly() {
+		t.Error("the wrapped action is not read only")
+	}
+
+	watch := NewWatchAction(ask, time.Hour, c)
+	if p, err := payloadWith(watch, &conf, c.IO()); err != nil || p != "typed" {
+		t.Errorf("the watch's payload is %v, %v", p, err)
+	}
+}
+
+func TestHistoryActions(t *testing.T) {
+	var conf Config = map[string]int{}
+	c := quietCommands(&conf)
+	defer c.Shutdown(context.Background())
+	c.Set(Build().WithNameV("a"))
+	c.Set(Build().WithNameV("b"))
+	first := run(t, c, "a")
+	time.Sleep(200 * time.Millisecond)
+	second := run(t, c, "a")
+	last := run(t, c, "b")
+
+	for line, want := range map[string]*Work{"lookup a": second, "lookup b": last, "last": last, "lookup c": nil} {
+		if res, err := run(t, c, line).Res(); err != nil || res.(*Work) != want {
+			t.Errorf("%s is %v, %v", line, res, err)
+		}
+	}
+	for line, want := range map[string][]*Work{
+		"history -n a":          {second, first},
+		"history -n a -d 100ms": {second},
+		"history -n a -u 100ms": {first},
+		"history -n b -u 1h":    nil,
+	} {
+		res, err := run(t, c, line).Res()
+		if ws, _ := res.([]*Work); err != nil || len(ws) != len(want) {
+			t.Errorf("%s is %v, %v", line, res, err)
+		} else {
+			for i := range ws {
+				if ws[i] != want[i] {
+					t.Errorf("%s is %v", line, ws)
+				}
+			}
+		}
+	}
+}
+
+// canceled queued work makes room in a full queue, while the work before it still runs
+func TestCancelMakesRoomInQueue(t *testing.T) {
+	var conf Config = map[string]int{}
+	c := quietCommands(&conf, WithQueueSize(1))
+	defer c.Shutdown(context.Background())
+	release := make(chan struct{})
+	c.Set(Build().WithNameV("block").WithVoidExecuteVoid(func(*Config) error {
+		<-release
+		return nil
+	}))
+	c.Set(Build().WithNameV("other"))
+	running, err := c.Run("block")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for running.Status() != "running" {
+		time.Sleep(time.Millisecond)
+	}
+	done := make(chan *Work, 1)
+	go func() {
+		for i := 0; i < 3; i++ {
+			if queued, err := c.Run("block"); err == nil {
+				queued.Cancel()
+			}
+		}
+		w, _ := c.Run("other")
+		done <- w
+	}()
+	var other *Work
+	select {
+	case other = <-done:
+	case <-time.After(5 * time.Second):
+		close(release)
+		t.Fatal("queueing waits behind canceled work")
+	}
+	close(release)
+	if other.Wait(context.Background()); other.Status() != "success" {
+		t.Errorf("the work queued after canceled work is %s", other.Status())
+	}
+}
+
+// keys are forgotten once their last work is done
+func TestKeysArePruned(t *testing.T) {
+	var conf Config = map[string]int{}
+	c := quietCommands(&conf, WithWorkers(4))
+	for i := 0; i < 20; i++ {
+		c.Set(Build().WithNameV(fmt.Sprintf("k%d", i)).WithConcurrencyKey(fmt.Sprintf("k%d", i)))
+	}
+	var ws []*Work
+	for j := 0; j < 3; j++ {
+		for i := 0; i < 20; i++ {
+			w, err := c.Run(fmt.Sprintf("k%d", i))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ws = append(ws, w)
+		}
+	}
+	c.Shutdown(context.Background())
+	for _, w := range ws {
+		if w.Status() != "success" {
+			t.Errorf("%s is %s", w.Name, w.Status())
+		}
+	}
+	c.workChan.mu.Lock()
+	defer c.workChan.mu.Unlock()
+	if n := len(c.workChan.lastByKey); n != 0 {
+		t.Errorf("%d keys are kept", n)
+	}
+}
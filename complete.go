@@ -0,0 +1,129 @@
+package commander
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// Completer is an optional sub-interface of Action.
+// When an action implements it, Commands.Complete asks it for the words that can complete its arguments.
+// args are the arguments typed so far, the last one is the word being completed, and may be empty.
+// Complete can return every word the argument could be, those not starting with the word are dropped.
+// It is called without holding the Config, so tab does not wait on work. It runs while work may be changing the
+// Config, so a Complete that reads the Config must guard what it reads itself.
+type Completer interface {
+	Complete(conf *Config, args []string) []string
+}
+
+// Candidate is a word that can complete a command line, see Commands.Complete
+type Candidate struct {
+	// the word
+	Value string
+	// what the word is, the description of the action it names, if it names one
+	Desc string
+	// the byte offset in the line of the word Value replaces
+	Start int
+}
+
+// Complete returns the candidates for the word ending at cursor in line.
+// The first word completes with the names and aliases of the actions, and
+// flags complete with the flags in the action's Meta. Other words are completed by
+// the action, when it is a Completer, without waiting on work.
+func (c *Commands) Complete(line string, cursor int) (out []Candidate) {
+	if cursor < 0 || cursor > len(line) {
+		cursor = len(line)
+	}
+	start := strings.LastIndexAny(line[:cursor], " \t") + 1
+	word := line[start:cursor]
+	fields, err := SplitArgs(line[:start])
+	if err != nil {
+		// the word is inside quotes, there is nothing sensible to complete
+		return nil
+	}
+	snap := c.Snapshot()
+	if len(fields) == 0 {
+		for _, k := range snap.KnownCommands() {
+			if strings.HasPrefix(k, word) {
+				out = append(out, Candidate{Value: k, Desc: snap[k].Desc(), Start: start})
+			}
+		}
+		return out
+	}
+	a, ok := snap[strings.ToLower(fields[0])]
+	if !ok {
+		return nil
+	}
+	if strings.HasPrefix(word, "-") {
+		if d, ok := a.(Describer); ok {
+			for _, kv := range d.Meta().Args {
+				if f := "--" + kv.Key; strings.HasPrefix(f, word) {
+					out = append(out, Candidate{Value: f, Desc: kv.Q, Start: start})
+				}
+			}
+		}
+		return out
+	}
+	comp, ok := a.(Completer)
+	if !ok {
+		return nil
+	}
+	for _, v := range comp.Complete(c.conf, append(fields[1:], word)) {
+		if strings.HasPrefix(v, word) {
+			out = append(out, Candidate{Value: v, Start: start})
+		}
+	}
+	return out
+}
+
+// CompletePath returns the files and directories that prefix can complete to,
+// directories end in a separator. A leading ~ is kept, and hidden files are only returned
+// when prefix names them.
+func CompletePath(prefix string) (out []string) {
+	dir, base := "", prefix
+	if i := strings.LastIndex(prefix, string(filepath.Separator)); i >= 0 {
+		dir, base = prefix[:i+1], prefix[i+1:]
+	}
+	read := dir
+	if err := ReplaceHome(&read); err != nil {
+		return nil
+	}
+	if read == "" {
+		read = "."
+	}
+	infos, err := ioutil.ReadDir(read)
+	if err != nil {
+		return nil
+	}
+	for _, v := range infos {
+		name := v.Name()
+		if !strings.HasPrefix(name, base) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".")) {
+			continue
+		}
+		if v.IsDir() {
+			name += string(filepath.Separator)
+		}
+		out = append(out, dir+name)
+	}
+	return out
+}
+
+// completeNames completes the first argument with the names and aliases known to c
+func (c *Commands) completeNames(_ *Config, args []string) []string {
+	if len(args) != 1 {
+		return nil
+	}
+	return c.KnownCommands()
+}
+
+// completeRan completes every argument with the names of the actions in the history, most recent first
+func (c *Commands) completeRan(_ *Config, _ []string) (out []string) {
+	seen := make(map[string]bool)
+	for _, w := range c.QueryHistory(HistoryFilter{}) {
+		if !seen[w.Name] {
+			seen[w.Name] = true
+			out = append(out, w.Name)
+		}
+	}
+	return out
+}
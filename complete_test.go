@@ -0,0 +1,39 @@
+package commander
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// completing does not wait on work that holds the Config
+func TestCompleteWhileWorkRuns(t *testing.T) {
+	var conf Config = map[string]int{}
+	c := quietCommands(&conf)
+	defer c.Shutdown(context.Background())
+	started, release := make(chan struct{}), make(chan struct{})
+	c.Set(Build().WithNameV("block").WithExecuteVoid(func(*Config) (interface{}, error) {
+		close(started)
+		<-release
+		return nil, nil
+	}))
+	c.Set(Build().WithNameV("pick").WithComplete(func(_ *Config, _ []string) []string {
+		return []string{"one", "two"}
+	}))
+	if _, err := c.Run("block"); err != nil {
+		t.Fatal(err)
+	}
+	defer close(release)
+	<-started
+
+	done := make(chan []Candidate)
+	go func() { done <- c.Complete("pick t", 6) }()
+	select {
+	case out := <-done:
+		if len(out) != 1 || out[0].Value != "two" || out[0].Start != 5 {
+			t.Errorf("candidates are %+v", out)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("completing waits on work")
+	}
+}
@@ -0,0 +1,169 @@
+package commander
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Meta is structured documentation of an action, shown by describe
+type Meta struct {
+	// how the action is called from a command line, like "load <file>"
+	Usage string
+	// the flags of the action, see WithQuestionsPayload
+	Args []KV
+	// example command lines
+	Examples []string
+}
+
+// Describer is an optional sub-interface of Action.
+// When an action implements it, describe shows its Meta along with its Desc.
+type Describer interface {
+	Meta() Meta
+}
+
+// Description is everything a Commands knows about one of its actions, see Commands.Describe
+type Description struct {
+	Meta
+	Name string
+	Desc string
+	Tags []string
+	// the other keys the action is stored at
+	Aliases []string
+	// the name of the action whose additions set this one, empty if it was set directly
+	AddedBy string
+	// the library that set the action, see Commands.Use
+	Library        string
+	ReadOnly       bool
+	ConcurrencyKey string
+}
+
+// Describe returns the Description of the action stored at key
+func (c *Commands) Describe(key string) (Description, error) {
+	c.cmdsMu.RLock()
+	addedBy, lib := c.addedBy[key], c.libOf[key]
+	c.cmdsMu.RUnlock()
+	snap := c.Snapshot()
+	a, ok := snap[key]
+	if !ok {
+		return Description{}, UnknownCommandError{Key: key, Suggestions: snap.Suggest(key)}
+	}
+	d := Description{Name: a.Name(), Desc: a.Desc(), Tags: a.Tags(), AddedBy: addedBy, Library: lib}
+	for _, v := range snap.Aliases(key) {
+		if v != d.Name {
+			d.Aliases = append(d.Aliases, v)
+		}
+	}
+	if m, ok := a.(Describer); ok {
+		d.Meta = m.Meta()
+	}
+	if r, ok := a.(ExecuteReadOnly); ok {
+		d.ReadOnly = r.ReadOnly()
+	}
+	if k, ok := a.(ConcurrencyKeyer); ok {
+		d.ConcurrencyKey = k.ConcurrencyKey()
+	}
+	return d, nil
+}
+
+// String formats d the way describe prints it
+func (d Description) String() string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "%s", d.Name)
+	if d.Desc != "" {
+		fmt.Fprintf(w, " - %s", d.Desc)
+	}
+	fmt.Fprintln(w)
+	if d.Usage != "" {
+		fmt.Fprintf(w, "usage:\n\t%s\n", d.Usage)
+	}
+	if len(d.Args) > 0 {
+		fmt.Fprintln(w, "arguments:")
+		for _, kv := range d.Args {
+			fmt.Fprintf(w, "\t%s\n", kvUsage(kv))
+		}
+	}
+	if len(d.Examples) > 0 {
+		fmt.Fprintf(w, "examples:\n\t%s\n", strings.Join(d.Examples, "\n\t"))
+	}
+	if len(d.Tags) > 0 {
+		fmt.Fprintf(w, "tags: %s\n", strings.Join(d.Tags, ", "))
+	}
+	if len(d.Aliases) > 0 {
+		fmt.Fprintf(w, "aliases: %s\n", strings.Join(d.Aliases, ", "))
+	}
+	if d.AddedBy != "" {
+		fmt.Fprintf(w, "added by: %s\n", d.AddedBy)
+	}
+	if d.Library != "" {
+		fmt.Fprintf(w, "library: %s\n", d.Library)
+	}
+	if d.ConcurrencyKey != "" {
+		fmt.Fprintf(w, "concurrency key: %s\n", d.ConcurrencyKey)
+	}
+	if d.ReadOnly {
+		fmt.Fprintln(w, "read only")
+	}
+	w.Flush()
+	return buf.String()
+}
+
+// kvUsage describes kv as a flag, a line of tab separated columns
+func kvUsage(kv KV) string {
+	flag := "--" + kv.Key
+	if kv.Short != "" {
+		flag += ", -" + kv.Short
+	}
+	hint := kv.Hint.String()
+	if len(kv.Choices) > 0 {
+		hint += " of " + strings.Join(kv.Choices, "|")
+	}
+	q := kv.Q
+	if kv.Required {
+		q += " (required)"
+	} else if !isZeroDefault(kv) {
+		q += fmt.Sprintf(" (default %v)", kv.Default)
+	}
+	return flag + "\t" + hint + "\t" + q
+}
+
+// isZeroDefault is true when kv's Default is the one NewKV gives its hint
+func isZeroDefault(kv KV) bool {
+	zero := NewKV("", "", kv.Hint).Default
+	return fmt.Sprint(zero) == fmt.Sprint(kv.Default)
+}
+
+// helpSections groups the actions in s by tag, for help.
+// Every action is listed by its name, once per tag, sorted by name.
+// Tags are sorted, with actions without tags under "other", and the default actions last.
+func helpSections(s Snapshot) (tags []string, sections map[string][]Action) {
+	sections = make(map[string][]Action)
+	for k, a := range s {
+		if _, ok := s[a.Name()]; ok && k != a.Name() {
+			continue
+		}
+		ts := a.Tags()
+		if len(ts) == 0 {
+			ts = []string{"other"}
+		}
+		for _, t := range ts {
+			if _, ok := sections[t]; !ok {
+				tags = append(tags, t)
+			}
+			sections[t] = append(sections[t], a)
+		}
+	}
+	sort.Slice(tags, func(i, k int) bool {
+		if (tags[i] == "default") != (tags[k] == "default") {
+			return tags[k] == "default"
+		}
+		return tags[i] < tags[k]
+	})
+	for _, as := range sections {
+		sort.Slice(as, func(i, k int) bool { return as[i].Name() < as[k].Name() })
+	}
+	return
+}
@@ -0,0 +1,63 @@
+package commander
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseFlags reads args as command line flags for kvs.
+// A KV is given as --Key=value, --Key value, -Short=value, or -Short value,
+// and its value is converted with KV.Parse, and checked with KV.Validate. A BOOL KV given without "=" is true.
+// It returns the values given keyed by KV.Key, and the kvs that were not given, in order.
+// Unknown flags, flags missing values, and arguments that are not flags are errors.
+func ParseFlags(kvs []KV, args []string) (map[string]interface{}, []KV, error) {
+	byName := make(map[string]KV)
+	for _, kv := range kvs {
+		byName["--"+kv.Key] = kv
+		if kv.Short != "" {
+			byName["-"+kv.Short] = kv
+		}
+	}
+	res := make(map[string]interface{})
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			return nil, nil, fmt.Errorf("unexpected argument: %s", arg)
+		}
+		name, value := arg, ""
+		hasValue := false
+		if eq := strings.Index(arg, "="); eq >= 0 {
+			name, value, hasValue = arg[:eq], arg[eq+1:], true
+		}
+		kv, ok := byName[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown flag: %s", name)
+		}
+		if !hasValue && kv.Hint == BOOL {
+			if err := kv.Validate(true); err != nil {
+				return nil, nil, fmt.Errorf("flag %s: %v", name, err)
+			}
+			res[kv.Key] = true
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return nil, nil, fmt.Errorf("flag %s needs a value", name)
+			}
+			i++
+			value = args[i]
+		}
+		v, err := kv.parseValid(value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("flag %s: %v", name, err)
+		}
+		res[kv.Key] = v
+	}
+	var missing []KV
+	for _, kv := range kvs {
+		if _, ok := res[kv.Key]; !ok {
+			missing = append(missing, kv)
+		}
+	}
+	return res, missing, nil
+}
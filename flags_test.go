@@ -0,0 +1,62 @@
+package commander
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFlags(t *testing.T) {
+	kvs := []KV{
+		NewKV("name?", "name", STR).WithShort("n"),
+		NewKV("count?", "count", INT).WithValidator(Min(1)),
+		NewKV("every?", "every", DUR),
+		NewKV("force?", "force", BOOL).WithShort("f"),
+	}
+	for _, v := range []struct {
+		args    []string
+		want    map[string]interface{}
+		missing []string
+		err     string
+	}{
+		{args: nil, want: map[string]interface{}{}, missing: []string{"name", "count", "every", "force"}},
+		{args: []string{"--name=a b"}, want: map[string]interface{}{"name": "a b"}, missing: []string{"count", "every", "force"}},
+		{args: []string{"--count", "3", "--every=1s"}, want: map[string]interface{}{"count": int64(3), "every": time.Second}, missing: []string{"name", "force"}},
+		{args: []string{"-n", "x", "-f"}, want: map[string]interface{}{"name": "x", "force": true}, missing: []string{"count", "every"}},
+		{args: []string{"--force", "--name", "--force"}, want: map[string]interface{}{"name": "--force", "force": true}, missing: []string{"count", "every"}},
+		{args: []string{"--force=no"}, want: map[string]interface{}{"force": false}, missing: []string{"name", "count", "every"}},
+		{args: []string{"--name="}, want: map[string]interface{}{"name": ""}, missing: []string{"count", "every", "force"}},
+		{args: []string{"--name"}, err: "flag --name needs a value"},
+		{args: []string{"-n"}, err: "flag -n needs a value"},
+		{args: []string{"--size=1"}, err: "unknown flag: --size"},
+		{args: []string{"-x"}, err: "unknown flag: -x"},
+		{args: []string{"--name", "a", "b"}, err: "unexpected argument: b"},
+		{args: []string{"a"}, err: "unexpected argument: a"},
+		{args: []string{"--count=many"}, err: "flag --count"},
+		{args: []string{"--count=0"}, err: "flag --count: count"},
+		{args: []string{"--force=maybe"}, err: `"maybe" is not yes or no`},
+	} {
+		got, missing, err := ParseFlags(kvs, v.args)
+		if v.err != "" {
+			if err == nil || !strings.Contains(err.Error(), v.err) {
+				t.Errorf("%q: error is %v, want %q", v.args, err, v.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", v.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, v.want) {
+			t.Errorf("%q: parsed %#v, want %#v", v.args, got, v.want)
+		}
+		var keys []string
+		for _, kv := range missing {
+			keys = append(keys, kv.Key)
+		}
+		if !reflect.DeepEqual(keys, v.missing) {
+			t.Errorf("%q: missing %q, want %q", v.args, keys, v.missing)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package commander
+
+import (
+	"sync"
+	"time"
+)
+
+// the default number of work History keeps
+const (
+	defaultActionHistory   = 10
+	defaultTimelineHistory = 100
+)
+
+// HistoryFilter picks work out of a History.
+// Zero fields do not filter anything.
+type HistoryFilter struct {
+	// only work of the action with this name
+	Name string
+	// only work that finished successfully
+	Success bool
+	// only work that failed
+	Failure bool
+	// only work created at or after Since
+	Since time.Time
+	// only work created before Until
+	Until time.Time
+	// at most Limit work, the most recent
+	Limit int
+}
+
+func (f HistoryFilter) match(w *Work) bool {
+	if f.Name != "" && w.Name != f.Name {
+		return false
+	}
+	if f.Success || f.Failure {
+		w.mu.Lock()
+		success, failure := w.Success, w.Failure
+		w.mu.Unlock()
+		if (f.Success && !success) || (f.Failure && !failure) {
+			return false
+		}
+	}
+	if !f.Since.IsZero() && w.CreatedAt.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !w.CreatedAt.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// History keeps the most recent work of every action, and a timeline of the most recent work of all actions.
+// It is safe for concurrent use.
+type History struct {
+	mu       sync.Mutex
+	size     int
+	byName   map[string]*ring
+	timeline *ring
+}
+
+// NewHistory returns a History that keeps perAction work of each action,
+// and timeline work overall. Sizes less than 1 use the defaults of 10 and 100.
+func NewHistory(perAction, timeline int) *History {
+	if perAction < 1 {
+		perAction = defaultActionHistory
+	}
+	if timeline < 1 {
+		timeline = defaultTimelineHistory
+	}
+	return &History{
+		size:     perAction,
+		byName:   make(map[string]*ring),
+		timeline: newRing(timeline),
+	}
+}
+
+// Add records w, dropping the oldest work when full
+func (h *History) Add(w *Work) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	r, ok := h.byName[w.Name]
+	if !ok {
+		r = newRing(h.size)
+		h.byName[w.Name] = r
+	}
+	r.add(w)
+	h.timeline.add(w)
+}
+
+// Latest returns the most recent work of the action named name, or nil
+func (h *History) Latest(name string) *Work {
+	if ws := h.Recent(name, 1); len(ws) > 0 {
+		return ws[0]
+	}
+	return nil
+}
+
+// Recent returns the n most recent work of the action named name, most recent first.
+// An empty name returns from the timeline of all actions, n less than 1 returns all that is kept.
+func (h *History) Recent(name string, n int) []*Work {
+	return h.Query(HistoryFilter{Name: name, Limit: n})
+}
+
+// Query returns the work matching f, most recent first
+func (h *History) Query(f HistoryFilter) (out []*Work) {
+	h.mu.Lock()
+	r := h.timeline
+	if f.Name != "" {
+		r = h.byName[f.Name]
+	}
+	var ws []*Work
+	if r != nil {
+		ws = r.newestFirst()
+	}
+	h.mu.Unlock()
+
+	for _, w := range ws {
+		if f.Limit > 0 && len(out) >= f.Limit {
+			break
+		}
+		if f.match(w) {
+			out = append(out, w)
+		}
+	}
+	return
+}
+
+// ring is a fixed size buffer of work that overwrites the oldest work
+type ring struct {
+	buf  []*Work
+	next int
+	full bool
+}
+
+func newRing(size int) *ring { return &ring{buf: make([]*Work, size)} }
+
+func (r *ring) add(w *Work) {
+	r.buf[r.next] = w
+	r.next = (r.next + 1) % len(r.buf)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+func (r *ring) newestFirst() (out []*Work) {
+	n := r.next
+	if r.full {
+		n = len(r.buf)
+	}
+	for i := 1; i <= n; i++ {
+		out = append(out, r.buf[(r.next-i+len(r.buf))%len(r.buf)])
+	}
+	return
+}
+
+// Status describes the state of the work in a word:
+// queued, running, success, failure, cancelled, or skipped
+func (w *Work) Status() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	switch {
+	case w.Cancelled && w.done:
+		return "cancelled"
+	case w.Success:
+		return "success"
+	case w.Failure:
+		return "failure"
+	case w.done:
+		return "skipped"
+	case w.started:
+		return "running"
+	}
+	return "queued"
+}
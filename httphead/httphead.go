@@ -0,0 +1,448 @@
+// Package httphead is an HTTP head for a commander.Commands.
+// It serves the actions as a JSON API, so other programs can run the same actions people run in a terminal:
+//
+//	GET  /actions               the actions, their tags, descriptions, and arguments
+//	GET  /actions/{name}        one action
+//	POST /actions/{name}        runs the action with the JSON body as its payload, nothing is prompted for
+//	GET  /work/{id}             the status of the work, and its result once it is done
+//	GET  /work/{id}?wait=10s    the same, once the work is done, or the wait is over
+//	GET  /work/{id}/events      server sent events, the status right away, and again once the work is done
+//
+// The default actions, like load and save, which read and write any file on the host, are not served
+// unless they are allowed with WithActionNames, WithActionTags, or WithAllActions.
+//
+//	commands := cmd.NewCommands(&config)
+//	log.Fatal(http.ListenAndServe(":8080", httphead.New(commands)))
+package httphead
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cmd "github.com/iamneal/commander"
+)
+
+// Server serves the actions of a Commands over HTTP, it is an http.Handler
+type Server struct {
+	cmds *cmd.Commands
+	// the most work kept for /work, the longest a request waits on work, and the largest payload read
+	keep    int
+	maxWait time.Duration
+	maxBody int64
+	// the actions served, see served
+	names map[string]bool
+	tags  map[string]bool
+	all   bool
+
+	// guards everything below
+	mu sync.Mutex
+	// the work ran through the server, by id, and their ids oldest first
+	work  map[int64]*cmd.Work
+	order []int64
+	next  int64
+}
+
+// Option changes a Server made by New
+type Option func(*Server)
+
+// WithKeep keeps the n most recent work for /work, 1000 by default
+func WithKeep(n int) Option { return func(s *Server) { s.keep = n } }
+
+// WithMaxWait is the longest a long poll, or an event stream, waits on work, one minute by default
+func WithMaxWait(d time.Duration) Option { return func(s *Server) { s.maxWait = d } }
+
+// WithMaxBody reads payloads of up to n bytes, larger ones are refused, 1MB by default
+func WithMaxBody(n int64) Option { return func(s *Server) { s.maxBody = n } }
+
+// WithActionNames serves the actions named names, along with those allowed by WithActionTags.
+// Only the allowed actions are served once either is given.
+func WithActionNames(names ...string) Option {
+	return func(s *Server) {
+		if s.names == nil {
+			s.names = make(map[string]bool)
+		}
+		for _, v := range names {
+			s.names[v] = true
+		}
+	}
+}
+
+// WithActionTags serves the actions with any of tags, along with those allowed by WithActionNames.
+// Only the allowed actions are served once either is given.
+func WithActionTags(tags ...string) Option {
+	return func(s *Server) {
+		if s.tags == nil {
+			s.tags = make(map[string]bool)
+		}
+		for _, v := range tags {
+			s.tags[v] = true
+		}
+	}
+}
+
+// WithAllActions serves every action, including the default ones, like load and save.
+// Only use it when every client may read and write the files of the host.
+func WithAllActions() Option { return func(s *Server) { s.all = true } }
+
+// New returns a Server for the actions of cmds
+func New(cmds *cmd.Commands, opts ...Option) *Server {
+	s := &Server{
+		cmds:    cmds,
+		keep:    1000,
+		maxWait: time.Minute,
+		maxBody: 1 << 20,
+		work:    make(map[int64]*cmd.Work),
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	return s
+}
+
+// Action is an action, as it is served
+type Action struct {
+	Name           string   `json:"name"`
+	Desc           string   `json:"desc"`
+	Tags           []string `json:"tags"`
+	Aliases        []string `json:"aliases,omitempty"`
+	Usage          string   `json:"usage,omitempty"`
+	Args           []Arg    `json:"args,omitempty"`
+	Examples       []string `json:"examples,omitempty"`
+	ReadOnly       bool     `json:"read_only"`
+	ConcurrencyKey string   `json:"concurrency_key,omitempty"`
+}
+
+// Arg is an argument of an action, from the KVs in its Meta
+type Arg struct {
+	Key      string      `json:"key"`
+	Short    string      `json:"short,omitempty"`
+	Question string      `json:"question,omitempty"`
+	Type     string      `json:"type"`
+	Default  interface{} `json:"default,omitempty"`
+	Required bool        `json:"required,omitempty"`
+	Choices  []string    `json:"choices,omitempty"`
+}
+
+// Work is the status of work, and its result once it is done
+type Work struct {
+	ID     int64  `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// the result, when the work succeeded
+	Result     json.RawMessage `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	switch parts := strings.Split(path, "/"); {
+	case path == "actions":
+		s.only(w, r, http.MethodGet, s.listActions)
+	case len(parts) == 2 && parts[0] == "actions" && r.Method == http.MethodPost:
+		s.runAction(w, r, parts[1])
+	case len(parts) == 2 && parts[0] == "actions":
+		s.only(w, r, http.MethodGet, func(w http.ResponseWriter, r *http.Request) { s.getAction(w, parts[1]) })
+	case len(parts) == 2 && parts[0] == "work":
+		s.only(w, r, http.MethodGet, func(w http.ResponseWriter, r *http.Request) { s.getWork(w, r, parts[1]) })
+	case len(parts) == 3 && parts[0] == "work" && parts[2] == "events":
+		s.only(w, r, http.MethodGet, func(w http.ResponseWriter, r *http.Request) { s.workEvents(w, r, parts[1]) })
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("not found: %s", r.URL.Path))
+	}
+}
+
+// only calls f when r's method is method, and fails with method not allowed otherwise
+func (s *Server) only(w http.ResponseWriter, r *http.Request, method string, f http.HandlerFunc) {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method))
+		return
+	}
+	f(w, r)
+}
+
+// listActions serves every action once, under its name, sorted by name
+func (s *Server) listActions(w http.ResponseWriter, _ *http.Request) {
+	names := make(map[string]bool)
+	for _, v := range s.cmds.Snapshot() {
+		if s.served(v) {
+			names[v.Name()] = true
+		}
+	}
+	out := []Action{}
+	for name := range names {
+		d, err := s.cmds.Describe(name)
+		if err != nil {
+			// removed since the snapshot
+			continue
+		}
+		out = append(out, fromDescription(d))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) getAction(w http.ResponseWriter, name string) {
+	if err := s.find(name); err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	d, err := s.cmds.Describe(name)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, fromDescription(d))
+}
+
+// runAction runs the action with the request's body as its payload, an empty body is a nil payload.
+// It answers with the queued work, and where to find it.
+func (s *Server) runAction(w http.ResponseWriter, r *http.Request, name string) {
+	if err := s.find(name); err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	var payload interface{}
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBody)
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && err != io.EOF {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("payload is larger than %d bytes", tooLarge.Limit))
+			return
+		}
+		writeError(w, http.StatusBadRequest, fmt.Errorf("payload is not json: %v", err))
+		return
+	}
+	work, err := s.cmds.RunWithPayload(name, payload)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	id := s.add(work)
+	w.Header().Set("Location", fmt.Sprintf("/work/%d", id))
+	writeJSON(w, http.StatusAccepted, status(id, work))
+}
+
+// getWork serves the status of work, after waiting up to the wait query parameter for it to be done
+func (s *Server) getWork(w http.ResponseWriter, r *http.Request, id string) {
+	n, work, err := s.get(id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if wait := r.URL.Query().Get("wait"); wait != "" {
+		d, err := time.ParseDuration(wait)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("wait is not a duration: %v", err))
+			return
+		}
+		s.wait(r, work, d)
+	}
+	writeJSON(w, http.StatusOK, status(n, work))
+}
+
+// workEvents streams the status of work as a "status" event,
+// and once it is done, or the wait is over, as a "done" or "timeout" event
+func (s *Server) workEvents(w http.ResponseWriter, r *http.Request, id string) {
+	n, work, err := s.get(id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	writeEvent(w, "status", status(n, work))
+	flusher.Flush()
+	if !s.wait(r, work, s.maxWait) {
+		if r.Context().Err() == nil {
+			writeEvent(w, "timeout", status(n, work))
+		}
+		return
+	}
+	writeEvent(w, "done", status(n, work))
+	flusher.Flush()
+}
+
+// wait waits up to d, and no longer than the server's longest wait, for work to be done.
+// It returns whether the work is done.
+func (s *Server) wait(r *http.Request, work *cmd.Work, d time.Duration) bool {
+	if d > s.maxWait {
+		d = s.maxWait
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), d)
+	defer cancel()
+	return work.Wait(ctx) == nil
+}
+
+// find returns an UnknownCommandError, suggesting only served actions, when no served action is stored at name
+func (s *Server) find(name string) error {
+	snap := s.cmds.Snapshot()
+	if a, ok := snap[strings.TrimSpace(strings.ToLower(name))]; ok && s.served(a) {
+		return nil
+	}
+	var suggestions []string
+	for _, v := range snap.Suggest(name) {
+		if s.served(snap[v]) {
+			suggestions = append(suggestions, v)
+		}
+	}
+	return cmd.UnknownCommandError{Key: name, Suggestions: suggestions}
+}
+
+// served is true when a is allowed by WithActionNames or WithActionTags, or when neither is given,
+// when it is not a default action
+func (s *Server) served(a cmd.Action) bool {
+	if s.all {
+		return true
+	}
+	if s.names == nil && s.tags == nil {
+		for _, t := range a.Tags() {
+			if t == "default" {
+				return false
+			}
+		}
+		return true
+	}
+	if s.names[a.Name()] {
+		return true
+	}
+	for _, t := range a.Tags() {
+		if s.tags[t] {
+			return true
+		}
+	}
+	return false
+}
+
+// add keeps work, dropping the oldest work kept when there is too much, and returns its id
+func (s *Server) add(work *cmd.Work) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next++
+	s.work[s.next] = work
+	s.order = append(s.order, s.next)
+	for s.keep > 0 && len(s.order) > s.keep {
+		delete(s.work, s.order[0])
+		s.order = s.order[1:]
+	}
+	return s.next
+}
+
+// get returns the work kept at id
+func (s *Server) get(id string) (int64, *cmd.Work, error) {
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return 0, nil, fmt.Errorf("unknown work: %s", id)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	work, ok := s.work[n]
+	if !ok {
+		return 0, nil, fmt.Errorf("unknown work: %s", id)
+	}
+	return n, work, nil
+}
+
+func fromDescription(d cmd.Description) Action {
+	a := Action{
+		Name:           d.Name,
+		Desc:           d.Desc,
+		Tags:           d.Tags,
+		Aliases:        d.Aliases,
+		Usage:          d.Usage,
+		Examples:       d.Examples,
+		ReadOnly:       d.ReadOnly,
+		ConcurrencyKey: d.ConcurrencyKey,
+	}
+	if a.Tags == nil {
+		a.Tags = []string{}
+	}
+	for _, kv := range d.Args {
+		a.Args = append(a.Args, Arg{
+			Key:      kv.Key,
+			Short:    kv.Short,
+			Question: kv.Q,
+			Type:     kv.Hint.String(),
+			Default:  kv.Default,
+			Required: kv.Required,
+			Choices:  kv.Choices,
+		})
+	}
+	return a
+}
+
+// status returns the status of work, with its result or error once it is done
+func status(id int64, work *cmd.Work) Work {
+	out := Work{ID: id, Name: work.Name, Status: work.Status(), CreatedAt: work.CreatedAt}
+	if !work.Done() {
+		return out
+	}
+	if !work.FinishedAt.IsZero() {
+		finished := work.FinishedAt
+		out.FinishedAt = &finished
+	}
+	res, err := work.Res()
+	if err != nil {
+		out.Error = err.Error()
+		return out
+	}
+	if res == nil {
+		return out
+	}
+	if out.Result, err = json.Marshal(res); err != nil {
+		out.Result, out.Error = nil, fmt.Sprintf("the result can not be encoded as json: %v", err)
+	}
+	return out
+}
+
+// statusOf returns the http status for an error from Commands
+func statusOf(err error) int {
+	switch {
+	case errors.As(err, &cmd.UnknownCommandError{}):
+		return http.StatusNotFound
+	case errors.As(err, &cmd.StoppedError{}):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadRequest
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes err as {"error": "...", "suggestions": [...]}, suggestions only for unknown commands
+func writeError(w http.ResponseWriter, code int, err error) {
+	body := struct {
+		Error       string   `json:"error"`
+		Suggestions []string `json:"suggestions,omitempty"`
+	}{Error: err.Error()}
+	var u cmd.UnknownCommandError
+	if errors.As(err, &u) {
+		body.Suggestions = u.Suggestions
+	}
+	writeJSON(w, code, body)
+}
+
+func writeEvent(w io.Writer, event string, v interface{}) {
+	data, _ := json.Marshal(v)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
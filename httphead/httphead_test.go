@@ -0,0 +1,223 @@
+package httphead
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	cmd "github.com/iamneal/commander"
+)
+
+// newServer serves "echo", which returns its payload, and "block", which returns once release is closed
+func newServer(t *testing.T, opts ...Option) (srv *httptest.Server, release chan struct{}) {
+	t.Helper()
+	var conf cmd.Config = map[string]interface{}{}
+	c := cmd.NewCommandsWithIO(&conf, cmd.NewIO(nil, ioutil.Discard, ioutil.Discard))
+	release = make(chan struct{})
+	c.Set(cmd.Build().WithNameV("echo").WithDescV("returns its payload").WithTagsV("test").
+		WithQuestionsPayload(cmd.NewKV("say what?", "say", cmd.STR)).
+		WithExecuteMap(func(_ *cmd.Config, m map[string]interface{}) (interface{}, error) { return m, nil }))
+	c.Set(cmd.Build().WithNameV("dial").WithTagsV("test").WithQuestionsPayload(
+		cmd.NewKV("host?", "host", cmd.STR).WithRequired(true),
+		cmd.NewKV("port?", "port", cmd.INT).WithDefault(int64(80)).WithValidator(cmd.Min(1)),
+	).WithExecuteMap(func(_ *cmd.Config, m map[string]interface{}) (interface{}, error) { return m, nil }))
+	c.Set(cmd.Build().WithNameV("block").WithExecuteVoid(func(*cmd.Config) (interface{}, error) {
+		<-release
+		return "released", nil
+	}))
+	srv = httptest.NewServer(New(c, append([]Option{WithMaxWait(5 * time.Second)}, opts...)...))
+	t.Cleanup(func() {
+		srv.Close()
+		c.Shutdown(context.Background())
+	})
+	return srv, release
+}
+
+// do sends body to path, and decodes the response into out, when out is not nil
+func do(t *testing.T, srv *httptest.Server, method, path, body string, out interface{}) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+	return res
+}
+
+type errorBody struct {
+	Error       string   `json:"error"`
+	Suggestions []string `json:"suggestions"`
+}
+
+func TestListActions(t *testing.T) {
+	srv, _ := newServer(t)
+	var actions []Action
+	if res := do(t, srv, http.MethodGet, "/actions", "", &actions); res.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", res.StatusCode)
+	}
+	names := make(map[string]Action)
+	for _, v := range actions {
+		names[v.Name] = v
+	}
+	echo, ok := names["echo"]
+	if !ok || echo.Desc != "returns its payload" || len(echo.Args) != 1 || echo.Args[0].Key != "say" {
+		t.Errorf("echo is %+v", echo)
+	}
+	for _, v := range []string{"load", "save", "quit"} {
+		if _, ok := names[v]; ok {
+			t.Errorf("%s is served by default", v)
+		}
+	}
+}
+
+func TestRunAction(t *testing.T) {
+	srv, _ := newServer(t)
+	var work Work
+	res := do(t, srv, http.MethodPost, "/actions/echo", `{"say": "hi"}`, &work)
+	if res.StatusCode != http.StatusAccepted {
+		t.Fatalf("status %d", res.StatusCode)
+	}
+	if loc := res.Header.Get("Location"); loc != fmt.Sprintf("/work/%d", work.ID) {
+		t.Errorf("location is %q for work %d", loc, work.ID)
+	}
+	do(t, srv, http.MethodGet, fmt.Sprintf("/work/%d?wait=5s", work.ID), "", &work)
+	if work.Status != "success" || string(work.Result) != `{"say":"hi"}` {
+		t.Errorf("work is %+v, with result %s", work, work.Result)
+	}
+}
+
+func TestRunUnknownAction(t *testing.T) {
+	srv, _ := newServer(t)
+	var body errorBody
+	if res := do(t, srv, http.MethodPost, "/actions/ecoh", `{}`, &body); res.StatusCode != http.StatusNotFound {
+		t.Fatalf("status %d", res.StatusCode)
+	}
+	if len(body.Suggestions) != 1 || body.Suggestions[0] != "echo" {
+		t.Errorf("suggestions are %v", body.Suggestions)
+	}
+}
+
+func TestRunBadPayload(t *testing.T) {
+	srv, _ := newServer(t, WithMaxBody(32))
+	if res := do(t, srv, http.MethodPost, "/actions/echo", `{bad`, nil); res.StatusCode != http.StatusBadRequest {
+		t.Errorf("bad json is status %d", res.StatusCode)
+	}
+	big := fmt.Sprintf(`{"say": %q}`, strings.Repeat("a", 64))
+	if res := do(t, srv, http.MethodPost, "/actions/echo", big, nil); res.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("large payload is status %d", res.StatusCode)
+	}
+}
+
+func TestRunInvalidPayload(t *testing.T) {
+	srv, _ := newServer(t)
+	for body, want := range map[string]string{
+		`{"host": "h", "port": -5}`: "port",
+		`{"port": 8080}`:            "host is required",
+	} {
+		var e errorBody
+		if res := do(t, srv, http.MethodPost, "/actions/dial", body, &e); res.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s is status %d", body, res.StatusCode)
+		} else if !strings.Contains(e.Error, want) {
+			t.Errorf("%s is %q", body, e.Error)
+		}
+	}
+	var work Work
+	if res := do(t, srv, http.MethodPost, "/actions/dial", `{"host": "h"}`, &work); res.StatusCode != http.StatusAccepted {
+		t.Fatalf("status %d", res.StatusCode)
+	}
+	do(t, srv, http.MethodGet, fmt.Sprintf("/work/%d?wait=5s", work.ID), "", &work)
+	if string(work.Result) != `{"host":"h","port":80}` {
+		t.Errorf("result is %s", work.Result)
+	}
+}
+
+func TestServedActions(t *testing.T) {
+	srv, _ := newServer(t)
+	for _, v := range []string{"load", "save"} {
+		if res := do(t, srv, http.MethodPost, "/actions/"+v, `{"path": "/tmp/x"}`, nil); res.StatusCode != http.StatusNotFound {
+			t.Errorf("%s is status %d", v, res.StatusCode)
+		}
+	}
+
+	srv, _ = newServer(t, WithActionTags("test"), WithActionNames("block"))
+	var actions []Action
+	do(t, srv, http.MethodGet, "/actions", "", &actions)
+	if len(actions) != 3 || actions[0].Name != "block" || actions[1].Name != "dial" || actions[2].Name != "echo" {
+		t.Errorf("actions are %+v", actions)
+	}
+	var body errorBody
+	if res := do(t, srv, http.MethodGet, "/actions/help", "", &body); res.StatusCode != http.StatusNotFound {
+		t.Errorf("help is status %d", res.StatusCode)
+	}
+
+	srv, _ = newServer(t, WithAllActions())
+	if res := do(t, srv, http.MethodGet, "/actions/save", "", nil); res.StatusCode != http.StatusOK {
+		t.Errorf("save is status %d with every action served", res.StatusCode)
+	}
+}
+
+func TestWaitForWork(t *testing.T) {
+	srv, release := newServer(t)
+	var work Work
+	do(t, srv, http.MethodPost, "/actions/block", "", &work)
+	path := fmt.Sprintf("/work/%d", work.ID)
+
+	do(t, srv, http.MethodGet, path+"?wait=10ms", "", &work)
+	if work.Status == "success" {
+		t.Errorf("work is done before it is released")
+	}
+	if res := do(t, srv, http.MethodGet, path+"?wait=soon", "", nil); res.StatusCode != http.StatusBadRequest {
+		t.Errorf("bad wait is status %d", res.StatusCode)
+	}
+	close(release)
+	do(t, srv, http.MethodGet, path+"?wait=5s", "", &work)
+	if work.Status != "success" || string(work.Result) != `"released"` {
+		t.Errorf("work is %+v, with result %s", work, work.Result)
+	}
+	if res := do(t, srv, http.MethodGet, "/work/99", "", nil); res.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown work is status %d", res.StatusCode)
+	}
+}
+
+func TestWorkEvents(t *testing.T) {
+	srv, release := newServer(t)
+	var work Work
+	do(t, srv, http.MethodPost, "/actions/block", "", &work)
+	res, err := http.Get(fmt.Sprintf("%s/work/%d/events", srv.URL, work.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("content type is %q", ct)
+	}
+	var events []string
+	sc := bufio.NewScanner(res.Body)
+	for sc.Scan() {
+		if strings.HasPrefix(sc.Text(), "event: ") {
+			events = append(events, strings.TrimPrefix(sc.Text(), "event: "))
+			if len(events) == 1 {
+				close(release)
+			}
+		}
+	}
+	if strings.Join(events, ",") != "status,done" {
+		t.Errorf("events are %v", events)
+	}
+}
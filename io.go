@@ -0,0 +1,140 @@
+package commander
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+)
+
+// IO is the set of streams a Commands instance prompts and prints with.
+// Questions and results are written to Out, problems are written to Err,
+// and answers are read a line at a time from In.
+// A nil *IO is usable, and behaves the same as StdIO.
+type IO struct {
+	in  *bufio.Reader
+	out io.Writer
+	err io.Writer
+	// guards out and err, Execute functions write from the worker
+	// while payloads are prompting on the calling thread
+	mu sync.Mutex
+}
+
+// StdIO is the IO used when none is provided. It is attached to the terminal.
+var StdIO = NewIO(os.Stdin, os.Stdout, os.Stderr)
+
+// NewIO returns an IO that reads answers from in, and writes to out and errOut.
+// A nil writer discards everything written to it.
+func NewIO(in io.Reader, out, errOut io.Writer) *IO {
+	if in == nil {
+		in = strings.NewReader("")
+	}
+	if out == nil {
+		out = ioutil.Discard
+	}
+	if errOut == nil {
+		errOut = ioutil.Discard
+	}
+	return &IO{in: bufio.NewReader(in), out: out, err: errOut}
+}
+
+func (i *IO) or() *IO {
+	if i == nil {
+		return StdIO
+	}
+	return i
+}
+
+// In returns the reader answers are read from.
+// Heads reading their own input should read from it, so nothing is lost between their reads and the questions' reads.
+func (i *IO) In() *bufio.Reader { return i.or().in }
+
+// Out returns the writer results are printed to
+func (i *IO) Out() io.Writer { return i.or().out }
+
+// Err returns the writer problems are printed to
+func (i *IO) Err() io.Writer { return i.or().err }
+
+// Printf formats according to a format specifier and writes to Out
+func (i *IO) Printf(format string, a ...interface{}) {
+	i = i.or()
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	fmt.Fprintf(i.out, format, a...)
+}
+
+// Println writes its operands to Out followed by a newline
+func (i *IO) Println(a ...interface{}) {
+	i = i.or()
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	fmt.Fprintln(i.out, a...)
+}
+
+// Eprintf formats according to a format specifier and writes to Err
+func (i *IO) Eprintf(format string, a ...interface{}) {
+	i = i.or()
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	fmt.Fprintf(i.err, format, a...)
+}
+
+// Dashes writes s to Out surrounded by dashes
+func (i *IO) Dashes(s string) {
+	i.Printf("---------%s---------\n", s)
+}
+
+// ReadLine prints question, and returns the next line read from In without its line ending.
+// io.EOF is only returned if nothing was read.
+func (i *IO) ReadLine(question string) (string, error) {
+	i = i.or()
+	i.Printf("%s\n>>> ", question)
+	line, err := i.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Scan prints question, and scans the answer into pointer.
+// An empty answer leaves pointer untouched and is not an error.
+// A *string pointer is given the whole trimmed line, so answers may contain spaces.
+func (i *IO) Scan(question string, pointer interface{}) error {
+	line, err := i.ReadLine(question)
+	if err != nil {
+		return err
+	}
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil
+	}
+	if s, ok := pointer.(*string); ok {
+		*s = line
+		return nil
+	}
+	_, err = fmt.Sscanln(line, pointer)
+	return err
+}
+
+// Scanner returns a function that calls Scan, useful with retry
+func (i *IO) Scanner(question string, pointer interface{}) func() error {
+	return func() error { return i.Scan(question, pointer) }
+}
+
+// IOPayloader is an optional sub-interface of Action.
+// When an action implements it, Commands calls IOPayload with its own IO instead
+// of calling Payload, so questions are asked on the streams the Commands was made with.
+type IOPayloader interface {
+	IOPayload(*Config, *IO) (interface{}, error)
+}
+
+// payloadWith calls a's IOPayload if it is implemented, and Payload otherwise
+func payloadWith(a Action, conf *Config, io *IO) (interface{}, error) {
+	if p, ok := a.(IOPayloader); ok {
+		return p.IOPayload(conf, io)
+	}
+	return a.Payload(conf)
+}
@@ -0,0 +1,104 @@
+package commander
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Encoder writes a value to a stream, *json.Encoder and *gob.Encoder are Encoders
+type Encoder interface {
+	Encode(interface{}) error
+}
+
+// Decoder reads the next value from a stream, returning io.EOF at the end of it.
+// *json.Decoder and *gob.Decoder are Decoders
+type Decoder interface {
+	Decode(interface{}) error
+}
+
+// JournalEntry is the record of one work in a Journal
+type JournalEntry struct {
+	// the order the work was dispatched in
+	Seq        int64       `json:"seq"`
+	Name       string      `json:"name"`
+	Payload    interface{} `json:"payload"`
+	Result     interface{} `json:"result,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	Success    bool        `json:"success"`
+	Cancelled  bool        `json:"cancelled,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+	FinishedAt time.Time   `json:"finished_at"`
+}
+
+// Journal is an append only record of the work a Commands runs, see Commands.SetJournal.
+// Entries are written as work finishes, so they are not always in the order they were dispatched,
+// JournalEntry.Seq keeps that order.
+type Journal struct {
+	mu  sync.Mutex
+	enc Encoder
+	seq int64
+}
+
+// NewJournal returns a Journal that writes each entry to enc
+func NewJournal(enc Encoder) *Journal {
+	return &Journal{enc: enc}
+}
+
+// NewJSONJournal returns a Journal that writes each entry to w as a line of json
+func NewJSONJournal(w io.Writer) *Journal {
+	return NewJournal(json.NewEncoder(w))
+}
+
+// next returns the sequence number of the next dispatched work, 0 for a nil Journal
+func (j *Journal) next() int64 {
+	if j == nil {
+		return 0
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.seq++
+	return j.seq
+}
+
+// Record writes the finished work w as the entry numbered seq
+func (j *Journal) Record(seq int64, w *Work) error {
+	w.mu.Lock()
+	e := JournalEntry{
+		Seq:        seq,
+		Name:       w.Name,
+		Payload:    w.payload,
+		Success:    w.Success,
+		Cancelled:  w.Cancelled,
+		CreatedAt:  w.CreatedAt,
+		FinishedAt: w.FinishedAt,
+	}
+	if w.err != nil {
+		e.Error = w.err.Error()
+	} else {
+		e.Result = w.Result
+	}
+	w.mu.Unlock()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.enc.Encode(e)
+}
+
+// ReadJournal reads every entry from dec, and returns them in the order they were dispatched
+func ReadJournal(dec Decoder) ([]JournalEntry, error) {
+	var out []JournalEntry
+	for {
+		var e JournalEntry
+		if err := dec.Decode(&e); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	sort.SliceStable(out, func(i, k int) bool { return out[i].Seq < out[k].Seq })
+	return out, nil
+}
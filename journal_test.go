@@ -0,0 +1,97 @@
+package commander
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type point struct{ X, Y int }
+
+// journaledCommands has the actions of the journal tests, got keeps the payloads their work is given.
+// block returns once release is closed.
+func journaledCommands(got *[]interface{}, release chan struct{}) *Commands {
+	var conf Config = map[string]int{}
+	c := quietCommands(&conf)
+	c.Set(Build().WithNameV("set").WithQuestionsPayload(
+		NewKV("n?", "n", INT),
+		NewKV("every?", "every", DUR),
+		NewKV("tags?", "tags", LIST),
+	).WithExecuteMap(func(_ *Config, m map[string]interface{}) (interface{}, error) {
+		*got = append(*got, m)
+		return nil, nil
+	}))
+	c.Set(NewTypedAction[map[string]int, point, int]("move").WithExecute(func(_ *map[string]int, p point) (int, error) {
+		*got = append(*got, p)
+		return p.X + p.Y, nil
+	}).Action())
+	c.Set(Build().WithNameV("block").WithExecuteVoid(func(*Config) (interface{}, error) {
+		<-release
+		return nil, nil
+	}))
+	c.Set(Build().WithNameV("count").WithExecute(func(_ *Config, p interface{}) (interface{}, error) {
+		*got = append(*got, p)
+		return nil, nil
+	}))
+	return c
+}
+
+func TestJournalReplay(t *testing.T) {
+	var journal bytes.Buffer
+	var got []interface{}
+	release := make(chan struct{})
+	c := journaledCommands(&got, release)
+	c.SetJournal(NewJSONJournal(&journal))
+	set := map[string]interface{}{"n": int64(3), "every": 2 * time.Second, "tags": []string{"a", "b"}}
+	for _, v := range []struct {
+		name    string
+		payload interface{}
+	}{{"set", set}, {"move", point{1, 2}}} {
+		w, err := c.RunWithPayload(v.name, v.payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Wait(context.Background())
+	}
+	blocked, err := c.RunWithPayload("block", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancelled, err := c.RunWithPayload("count", "cancelled")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancelled.Cancel()
+	close(release)
+	blocked.Wait(context.Background())
+	if _, err := c.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ReadJournal(json.NewDecoder(bytes.NewReader(journal.Bytes())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 || !entries[3].Cancelled {
+		t.Fatalf("journal is %+v", entries)
+	}
+
+	var replayed []interface{}
+	closed := make(chan struct{})
+	close(closed)
+	r := journaledCommands(&replayed, closed)
+	defer r.Shutdown(context.Background())
+	work, err := r.Replay(json.NewDecoder(&journal))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(work) != 3 {
+		t.Errorf("replayed %d work", len(work))
+	}
+	if want := []interface{}{set, point{1, 2}}; !reflect.DeepEqual(replayed, want) {
+		t.Errorf("replayed payloads %#v, want %#v", replayed, want)
+	}
+}
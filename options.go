@@ -0,0 +1,109 @@
+package commander
+
+import (
+	"strconv"
+)
+
+// Logger receives what a Commands reports about the work it runs, *log.Logger is a Logger
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// UnknownCommandHandler is given a key that no action is stored at, and returns the action to run instead.
+// Returning nil makes running the key an error.
+type UnknownCommandHandler func(c *Commands, key string) Action
+
+// HelpUnknownCommand is an UnknownCommandHandler that reports the key as unknown,
+// along with what it may have meant, and runs help.
+func HelpUnknownCommand(c *Commands, key string) Action {
+	c.io.Println("\t" + UnknownCommandError{Key: key, Suggestions: c.Suggest(key)}.Error())
+	c.cmdsMu.RLock()
+	defer c.cmdsMu.RUnlock()
+	return c.cmds["help"]
+}
+
+// WithQueueSize lets n work be queued before queueing blocks, an n below 1 is 1, see OptQueueSize
+func WithQueueSize(n int) opt { return Opt(OptQueueSize, strconv.Itoa(n)) }
+
+// WithWorkers runs up to n work at the same time, an n below 1 is 1, see OptWorkers
+func WithWorkers(n int) opt { return Opt(OptWorkers, strconv.Itoa(n)) }
+
+// WithHistory keeps perAction work of each action, and timeline work overall, see NewHistory
+func WithHistory(perAction, timeline int) opt {
+	return opt{key: "history", apply: func(c *Commands) {
+		c.workChan.history = NewHistory(perAction, timeline)
+	}}
+}
+
+// WithPropagatePanics lets panicking jobs crash the program, see OptPropagatePanics
+func WithPropagatePanics() opt { return Opt(OptPropagatePanics, "true") }
+
+// WithoutDefaults does not set the default actions, see OptDefaults
+func WithoutDefaults() opt { return Opt(OptDefaults, "false") }
+
+// WithIO prompts and prints through io, see NewCommandsWithIO
+func WithIO(io *IO) opt {
+	return opt{key: "io", apply: func(c *Commands) {
+		if io != nil {
+			c.io = io
+		}
+	}}
+}
+
+// WithLogger reports the progress of work, and problems that do not stop anything, to l
+// instead of the Commands' IO
+func WithLogger(l Logger) opt {
+	return opt{key: "logger", apply: func(c *Commands) { c.logger = l }}
+}
+
+// WithUnknownCommandHandler decides what runs in place of unknown commands with h,
+// SuggestUnknownCommand(false) by default. A nil h makes every unknown command an UnknownCommandError.
+func WithUnknownCommandHandler(h UnknownCommandHandler) opt {
+	return opt{key: "unknown-command-handler", apply: func(c *Commands) {
+		if h == nil {
+			h = func(*Commands, string) Action { return nil }
+		}
+		c.unknown = h
+	}}
+}
+
+// WithCodec sets load and save actions that use codec, see Commands.SetCodec
+func WithCodec(codec Codec) opt {
+	return opt{key: "codec", apply: func(c *Commands) { c.SetCodec(codec) }}
+}
+
+// WithFactory decodes the Config into values made by f, see Commands.SetFactory.
+// Give it before WithAutosave, so the autosave is restored with it.
+func WithFactory(f Factory) opt {
+	return opt{key: "factory", apply: func(c *Commands) { c.SetFactory(f) }}
+}
+
+// WithJournal records every work to j, see Commands.SetJournal
+func WithJournal(j *Journal) opt {
+	return opt{key: "journal", apply: func(c *Commands) { c.SetJournal(j) }}
+}
+
+// WithUndo snapshots the Config for undo and redo, see Commands.EnableUndo
+func WithUndo(cloner Cloner, depth int) opt {
+	return opt{key: "undo", apply: func(c *Commands) { c.EnableUndo(cloner, depth) }}
+}
+
+// WithAutosave saves the Config as it changes, see Commands.EnableAutosave.
+// Errors are reported as problems, and leave autosave disabled.
+func WithAutosave(a Autosave) opt {
+	return opt{key: "autosave-config", apply: func(c *Commands) {
+		if err := c.EnableAutosave(a); err != nil {
+			c.warn("autosave is not enabled: %v\n", err)
+		}
+	}}
+}
+
+// WithLibraries uses the registered libraries libs, see Commands.Use.
+// Errors are reported as problems, and leave every library unused.
+func WithLibraries(libs ...string) opt {
+	return opt{key: "libraries", apply: func(c *Commands) {
+		if err := c.Use(libs...); err != nil {
+			c.warn("could not use %v: %v\n", libs, err)
+		}
+	}}
+}
@@ -0,0 +1,134 @@
+package commander
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// the libraries of actions given to Register
+var registry = struct {
+	sync.RWMutex
+	libs map[string][]Action
+}{libs: make(map[string][]Action)}
+
+// Register makes actions available as the library lib, to Commands that Use it.
+// It is meant to be called from the init function of a package of actions, the way database/sql drivers register.
+// The same actions are given to every Commands using lib, so they should not keep state of their own.
+// Register panics if lib is already registered, if an action is nil, or if two actions share a name.
+func Register(lib string, actions ...Action) {
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := registry.libs[lib]; ok {
+		panic("commander: Register called twice for library " + lib)
+	}
+	names := make(map[string]bool)
+	for _, a := range actions {
+		if a == nil {
+			panic("commander: Register action is nil in library " + lib)
+		}
+		if names[a.Name()] {
+			panic("commander: Register called with two actions named " + a.Name() + " in library " + lib)
+		}
+		names[a.Name()] = true
+	}
+	registry.libs[lib] = append([]Action(nil), actions...)
+}
+
+// Libraries returns the names of the registered libraries, sorted
+func Libraries() (out []string) {
+	registry.RLock()
+	defer registry.RUnlock()
+	for k := range registry.libs {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return
+}
+
+// LibraryActions returns the actions registered as lib, or nil if there is no such library
+func LibraryActions(lib string) []Action {
+	registry.RLock()
+	defer registry.RUnlock()
+	return append([]Action(nil), registry.libs[lib]...)
+}
+
+// ConflictError is returned by Commands.Use when an action name is taken,
+// by another library in use, or by an action that is already set
+type ConflictError struct {
+	Name string
+	// the libraries that want the name, the name is taken by an action that was set directly
+	// when there is only one
+	Libs []string
+}
+
+func (e ConflictError) Error() string {
+	if len(e.Libs) == 1 {
+		return fmt.Sprintf("library %s can not set %s, it is already set", e.Libs[0], e.Name)
+	}
+	return fmt.Sprintf("libraries %s all set %s", strings.Join(e.Libs, ", "), e.Name)
+}
+
+// Use sets the actions of every registered library in libs.
+// Libraries already in use are skipped. If a library is unknown, or any of the names are taken,
+// see ConflictError, nothing is set.
+func (c *Commands) Use(libs ...string) error {
+	c.cmdsMu.Lock()
+	defer c.cmdsMu.Unlock()
+	wanted := make(map[string][]string)
+	adding := make(map[string]Action)
+	for _, lib := range libs {
+		if c.libs[lib] {
+			continue
+		}
+		actions := LibraryActions(lib)
+		if actions == nil {
+			return fmt.Errorf("unknown library: %s, known libraries are: %s", lib, strings.Join(Libraries(), ", "))
+		}
+		for _, a := range actions {
+			if _, ok := adding[a.Name()]; !ok {
+				adding[a.Name()] = a
+				wanted[a.Name()] = append(wanted[a.Name()], lib)
+			} else if last := wanted[a.Name()]; last[len(last)-1] != lib {
+				wanted[a.Name()] = append(last, lib)
+			}
+		}
+	}
+	names := make([]string, 0, len(wanted))
+	for k := range wanted {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if ls := wanted[name]; len(ls) > 1 {
+			return ConflictError{Name: name, Libs: ls}
+		}
+		if owner := c.libOf[name]; owner != "" {
+			return ConflictError{Name: name, Libs: append([]string{owner}, wanted[name]...)}
+		}
+		if _, ok := c.cmds[name]; ok {
+			return ConflictError{Name: name, Libs: wanted[name]}
+		}
+	}
+	for _, name := range names {
+		c.cmds[name] = adding[name]
+		c.libOf[name] = wanted[name][0]
+		delete(c.addedBy, name)
+	}
+	for _, lib := range libs {
+		c.libs[lib] = true
+	}
+	return nil
+}
+
+// Using returns the libraries this Commands uses, sorted
+func (c *Commands) Using() (out []string) {
+	c.cmdsMu.RLock()
+	defer c.cmdsMu.RUnlock()
+	for k := range c.libs {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return
+}
@@ -0,0 +1,95 @@
+package commander
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func init() {
+	Register("test-math", Build().WithNameV("add"), Build().WithNameV("sub"))
+	Register("test-more-math", Build().WithNameV("add"), Build().WithNameV("mul"))
+	Register("test-strings", Build().WithNameV("concat"))
+	Register("test-help", Build().WithNameV("help"))
+}
+
+func TestUseConflicts(t *testing.T) {
+	for _, v := range []struct {
+		name  string
+		using []string
+		use   []string
+		err   error
+	}{
+		{name: "two libraries at once", use: []string{"test-math", "test-more-math"},
+			err: ConflictError{Name: "add", Libs: []string{"test-math", "test-more-math"}}},
+		{name: "a library in use", using: []string{"test-math"}, use: []string{"test-strings", "test-more-math"},
+			err: ConflictError{Name: "add", Libs: []string{"test-math", "test-more-math"}}},
+		{name: "an action that is set", use: []string{"test-strings", "test-help"},
+			err: ConflictError{Name: "help", Libs: []string{"test-help"}}},
+	} {
+		var conf Config = map[string]int{}
+		c := quietCommands(&conf)
+		if err := c.Use(v.using...); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Use(v.use...); !reflect.DeepEqual(err, v.err) {
+			t.Errorf("%s: error is %v, want %v", v.name, err, v.err)
+		}
+		// nothing is set when Use fails
+		if !reflect.DeepEqual(c.Using(), v.using) {
+			t.Errorf("%s: using %v", v.name, c.Using())
+		}
+		snap := c.Snapshot()
+		for _, name := range []string{"sub", "mul", "concat"} {
+			if _, ok := snap[name]; ok != (name == "sub" && len(v.using) > 0) {
+				t.Errorf("%s: %s is set: %v", v.name, name, ok)
+			}
+		}
+		if _, ok := snap["help"].(*builderAction); ok {
+			t.Errorf("%s: help is replaced", v.name)
+		}
+		c.Shutdown(context.Background())
+	}
+}
+
+func TestUse(t *testing.T) {
+	var conf Config = map[string]int{}
+	c := quietCommands(&conf)
+	defer c.Shutdown(context.Background())
+	if err := c.Use("test-math", "test-strings"); err != nil {
+		t.Fatal(err)
+	}
+	// a library in use is skipped
+	if err := c.Use("test-math"); err != nil {
+		t.Error(err)
+	}
+	if err := c.Use("test-nothing"); err == nil || !strings.Contains(err.Error(), "unknown library: test-nothing") {
+		t.Errorf("error is %v", err)
+	}
+	if using := c.Using(); !reflect.DeepEqual(using, []string{"test-math", "test-strings"}) {
+		t.Errorf("using %v", using)
+	}
+	for _, name := range []string{"add", "sub", "concat"} {
+		if _, ok := c.Snapshot()[name]; !ok {
+			t.Errorf("%s is not set", name)
+		}
+	}
+}
+
+func TestRegisterPanics(t *testing.T) {
+	for name, f := range map[string]func(){
+		"twice":        func() { Register("test-math") },
+		"nil action":   func() { Register("test-nil", nil) },
+		"shared names": func() { Register("test-shared", Build().WithNameV("a"), Build().WithNameV("a")) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: Register did not panic", name)
+				}
+			}()
+			f()
+		}()
+	}
+}
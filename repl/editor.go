@@ -0,0 +1,330 @@
+package repl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	cmd "github.com/iamneal/commander"
+)
+
+// errInterrupt is returned by readLine when ctrl-c is pressed, the line is thrown away
+var errInterrupt = errors.New("interrupted")
+
+// the keys editor handles
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyBackspace = 8
+	keyTab       = 9
+	keyLF        = 10
+	keyCtrlK     = 11
+	keyCR        = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+// editor reads a line from a terminal in raw mode, echoing and editing it as keys are pressed.
+// What is printed while a line is read goes through print, above the line being edited.
+type editor struct {
+	io       *cmd.IO
+	prompt   string
+	history  *history
+	complete Completer
+
+	// guards reading, and the line being edited below
+	mu sync.Mutex
+	// a line is being read, the terminal is in raw mode and shows the prompt
+	reading bool
+	buf     []rune
+	pos     int
+	// the history line being shown, len(history.lines) is the line being typed
+	hpos int
+	// what was typed before moving through history
+	typed []rune
+	// the last key was tab, so a second tab lists the candidates
+	tabbed bool
+}
+
+// readLine prompts, and returns the line once enter is pressed.
+// ctrl-c returns errInterrupt, and ctrl-d on an empty line returns io.EOF.
+func (e *editor) readLine() (string, error) {
+	e.mu.Lock()
+	e.buf, e.pos, e.typed, e.tabbed = nil, 0, nil, false
+	e.hpos = len(e.history.lines)
+	e.reading = true
+	e.refresh()
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		e.reading = false
+		e.mu.Unlock()
+	}()
+	in := e.io.In()
+	for {
+		r, _, err := in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		e.mu.Lock()
+		line, done, err := e.key(r)
+		// stop drawing the prompt under what is printed before readLine returns
+		if done || err != nil {
+			e.reading = false
+		}
+		e.mu.Unlock()
+		if done || err != nil {
+			return line, err
+		}
+	}
+}
+
+// key edits the line for the key r, it returns the line once it is done
+func (e *editor) key(r rune) (string, bool, error) {
+	tabbed := false
+	switch r {
+	case keyCR, keyLF:
+		e.io.Printf("\r\n")
+		return string(e.buf), true, nil
+	case keyCtrlC:
+		e.io.Printf("^C\r\n")
+		return "", false, errInterrupt
+	case keyCtrlD:
+		if len(e.buf) == 0 {
+			e.io.Printf("\r\n")
+			return "", false, io.EOF
+		}
+		e.deleteAt(e.pos)
+	case keyBackspace, keyDelete:
+		if e.pos > 0 {
+			e.pos--
+			e.deleteAt(e.pos)
+		}
+	case keyCtrlA:
+		e.pos = 0
+	case keyCtrlE:
+		e.pos = len(e.buf)
+	case keyCtrlB:
+		e.left()
+	case keyCtrlF:
+		e.right()
+	case keyCtrlK:
+		e.buf = e.buf[:e.pos]
+	case keyCtrlU:
+		e.buf = append([]rune(nil), e.buf[e.pos:]...)
+		e.pos = 0
+	case keyCtrlW:
+		start := e.pos
+		for start > 0 && e.buf[start-1] == ' ' {
+			start--
+		}
+		for start > 0 && e.buf[start-1] != ' ' {
+			start--
+		}
+		e.buf = append(e.buf[:start], e.buf[e.pos:]...)
+		e.pos = start
+	case keyCtrlP:
+		e.moveHistory(-1)
+	case keyCtrlN:
+		e.moveHistory(1)
+	case keyTab:
+		e.completeWord()
+		tabbed = true
+	case keyEscape:
+		if err := e.escape(); err != nil {
+			return "", false, err
+		}
+	default:
+		if r >= ' ' {
+			e.insert(r)
+		}
+	}
+	e.tabbed = tabbed
+	e.refresh()
+	return "", false, nil
+}
+
+// print writes s to Out, or to Err with toErr. While a line is read, the line is cleared first,
+// and drawn again under s, so s is not mixed into what is being typed.
+func (e *editor) print(toErr bool, s string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	write := e.io.Printf
+	if toErr {
+		write = e.io.Eprintf
+	}
+	if !e.reading {
+		write("%s", s)
+		return
+	}
+	// the terminal is in raw mode, where a line feed does not return the cursor
+	s = strings.Replace(strings.TrimSuffix(s, "\n"), "\n", "\r\n", -1)
+	write("\r\x1b[K%s\r\n", s)
+	e.refresh()
+}
+
+// Printf prints what the Commands reports on Err, through print, the REPL makes the editor the Commands' Logger
+func (e *editor) Printf(format string, a ...interface{}) {
+	s := fmt.Sprintf(format, a...)
+	if !strings.HasSuffix(s, "\n") {
+		s += "\n"
+	}
+	e.print(true, s)
+}
+
+// escape handles the escape sequences of the arrow, home, end, and delete keys, and skips the ones it does not know.
+// A terminal writes a sequence all at once, so an escape with nothing after it is the escape key, which does nothing.
+func (e *editor) escape() error {
+	in := e.io.In()
+	if in.Buffered() == 0 {
+		return nil
+	}
+	b, err := in.ReadByte()
+	if err != nil || (b != '[' && b != 'O') {
+		return err
+	}
+	// ESC [ params final, where the final byte is in 0x40-0x7e, like ESC [ 1 ; 5 D for ctrl-left
+	var params []byte
+	for {
+		if b, err = in.ReadByte(); err != nil {
+			return err
+		}
+		if b >= 0x40 && b <= 0x7e {
+			break
+		}
+		params = append(params, b)
+	}
+	switch b {
+	case 'A':
+		e.moveHistory(-1)
+	case 'B':
+		e.moveHistory(1)
+	case 'C':
+		e.right()
+	case 'D':
+		e.left()
+	case 'H':
+		e.pos = 0
+	case 'F':
+		e.pos = len(e.buf)
+	case '~':
+		// ESC [ n ~, or ESC [ n ; modifiers ~
+		switch strings.SplitN(string(params), ";", 2)[0] {
+		case "1", "7":
+			e.pos = 0
+		case "4", "8":
+			e.pos = len(e.buf)
+		case "3":
+			e.deleteAt(e.pos)
+		}
+	}
+	return nil
+}
+
+func (e *editor) left() {
+	if e.pos > 0 {
+		e.pos--
+	}
+}
+
+func (e *editor) right() {
+	if e.pos < len(e.buf) {
+		e.pos++
+	}
+}
+
+func (e *editor) insert(rs ...rune) {
+	e.buf = append(e.buf[:e.pos], append(rs, e.buf[e.pos:]...)...)
+	e.pos += len(rs)
+}
+
+func (e *editor) deleteAt(i int) {
+	if i < len(e.buf) {
+		e.buf = append(e.buf[:i], e.buf[i+1:]...)
+	}
+}
+
+// moveHistory shows the line by older lines back in history, or newer lines forward when by is positive
+func (e *editor) moveHistory(by int) {
+	lines := e.history.lines
+	next := e.hpos + by
+	if next < 0 || next > len(lines) {
+		return
+	}
+	if e.hpos == len(lines) {
+		e.typed = e.buf
+	}
+	e.hpos = next
+	if next == len(lines) {
+		e.buf = e.typed
+	} else {
+		e.buf = []rune(lines[next])
+	}
+	e.pos = len(e.buf)
+}
+
+// completeWord replaces the word before the cursor with its only candidate,
+// or with the prefix all its candidates share. A second tab lists the candidates.
+func (e *editor) completeWord() {
+	if e.complete == nil {
+		return
+	}
+	line := string(e.buf[:e.pos])
+	// completing may wait on work that reports through print, only this goroutine changes the line
+	e.mu.Unlock()
+	cands := e.complete(string(e.buf), len(line))
+	e.mu.Lock()
+	if len(cands) == 0 {
+		return
+	}
+	word := []rune(lastWord(line))
+	if len(cands) == 1 {
+		e.replaceWord(len(word), cands[0]+" ")
+		return
+	}
+	if prefix := commonPrefix(cands); len([]rune(prefix)) > len(word) {
+		e.replaceWord(len(word), prefix)
+		return
+	}
+	if e.tabbed {
+		e.io.Printf("\r\n%s\r\n", strings.Join(cands, "  "))
+	}
+}
+
+// replaceWord replaces the n runes before the cursor with s
+func (e *editor) replaceWord(n int, s string) {
+	start := e.pos - n
+	e.buf = append(e.buf[:start], e.buf[e.pos:]...)
+	e.pos = start
+	e.insert([]rune(s)...)
+}
+
+// refresh redraws the prompt and line, and puts the cursor back where it is in the line
+func (e *editor) refresh() {
+	e.io.Printf("\r%s%s\x1b[K", e.prompt, string(e.buf))
+	if back := len(e.buf) - e.pos; back > 0 {
+		e.io.Printf("\x1b[%dD", back)
+	}
+}
+
+// commonPrefix returns the longest prefix shared by all of words
+func commonPrefix(words []string) string {
+	prefix := []rune(words[0])
+	for _, w := range words[1:] {
+		// trimmed a rune at a time, so a prefix never ends in part of one
+		for !strings.HasPrefix(w, string(prefix)) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return string(prefix)
+}
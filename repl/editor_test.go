@@ -0,0 +1,164 @@
+package repl
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	cmd "github.com/iamneal/commander"
+)
+
+// chunks is read a chunk at a time, the way a terminal returns what is typed
+type chunks []string
+
+func (c *chunks) Read(p []byte) (int, error) {
+	if len(*c) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, (*c)[0])
+	(*c)[0] = (*c)[0][n:]
+	if (*c)[0] == "" {
+		*c = (*c)[1:]
+	}
+	return n, nil
+}
+
+// newEditor returns an editor reading keys, with the history lines, and what it prints
+func newEditor(lines []string, complete Completer, keys ...string) (*editor, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	in := chunks(keys)
+	return &editor{
+		io:       cmd.NewIO(&in, out, out),
+		history:  &history{lines: lines, size: defaultHistorySize},
+		complete: complete,
+	}, out
+}
+
+func TestEditorKeys(t *testing.T) {
+	for _, v := range []struct {
+		name string
+		keys []string
+		want string
+	}{
+		{"typed", []string{"abc\r"}, "abc"},
+		{"line feed", []string{"abc\n"}, "abc"},
+		{"backspace", []string{"abc\x7f\x08\r"}, "a"},
+		{"ctrl-a", []string{"bc\x01a\r"}, "abc"},
+		{"ctrl-e", []string{"bc\x01a\x05d\r"}, "abcd"},
+		{"ctrl-b and ctrl-f", []string{"ac\x02\x02\x06b\r"}, "abc"},
+		{"ctrl-d deletes", []string{"abc\x02\x02\x04\r"}, "ac"},
+		{"ctrl-k", []string{"abc\x02\x02\x0b\r"}, "a"},
+		{"ctrl-u", []string{"abc\x02\x15\r"}, "c"},
+		{"ctrl-w", []string{"one two  \x17\r"}, "one "},
+		{"arrows", []string{"ac\x1b[Db\x1b[Cd\r"}, "abcd"},
+		{"ss3 arrows", []string{"ac\x1bODb\r"}, "abc"},
+		{"home and end", []string{"bc\x1b[Ha\x1b[Fd\r"}, "abcd"},
+		{"home and end with ~", []string{"bc\x1b[1~a\x1b[4~d\x1b[7~\x1b[8~e\r"}, "abcde"},
+		{"delete", []string{"abc\x1b[H\x1b[3~\r"}, "bc"},
+		{"ctrl-left", []string{"ac\x1b[1;5Db\r"}, "abc"},
+		{"ctrl-delete", []string{"abc\x1b[H\x1b[3;5~\r"}, "bc"},
+		{"unknown sequence", []string{"ab\x1b[200~c\x1b[15;2~\r"}, "abc"},
+		{"bare escape", []string{"ab", "\x1b", "c\r"}, "abc"},
+		{"alt key", []string{"ab\x1bxc\r"}, "abc"},
+	} {
+		e, _ := newEditor(nil, nil, v.keys...)
+		if line, err := e.readLine(); err != nil || line != v.want {
+			t.Errorf("%s: read %q, %v, want %q", v.name, line, err, v.want)
+		}
+	}
+}
+
+func TestEditorInterrupt(t *testing.T) {
+	for keys, want := range map[string]error{
+		"ab\x03": errInterrupt,
+		"\x04":   io.EOF,
+		"ab":     io.EOF,
+	} {
+		e, _ := newEditor(nil, nil, keys)
+		if line, err := e.readLine(); err != want || line != "" {
+			t.Errorf("%q: read %q, %v, want %v", keys, line, err, want)
+		}
+	}
+}
+
+func TestEditorHistory(t *testing.T) {
+	lines := []string{"first", "second"}
+	for _, v := range []struct {
+		name string
+		keys string
+		want string
+	}{
+		{"up", "\x1b[A\r", "second"},
+		{"up twice", "\x1b[A\x1b[A\r", "first"},
+		{"past the oldest", "\x10\x10\x10\r", "first"},
+		{"past the newest", "\x0e\r", ""},
+		{"back to what was typed", "new\x10\x10\x0e\x0e\r", "new"},
+		{"down", "\x1b[A\x1b[A\x1b[B\r", "second"},
+		{"edit a line", "\x10\x10!\r", "first!"},
+	} {
+		e, _ := newEditor(lines, nil, v.keys)
+		if line, err := e.readLine(); err != nil || line != v.want {
+			t.Errorf("%s: read %q, %v, want %q", v.name, line, err, v.want)
+		}
+	}
+	if lines[0] != "first" || lines[1] != "second" {
+		t.Errorf("history is changed to %q", lines)
+	}
+}
+
+func TestCompleteWord(t *testing.T) {
+	words := []string{"help", "print-config", "print-work", "quit"}
+	complete := func(line string, cursor int) (out []string) {
+		word := lastWord(line[:cursor])
+		for _, w := range words {
+			if strings.HasPrefix(w, word) {
+				out = append(out, w)
+			}
+		}
+		return out
+	}
+	for _, v := range []struct {
+		name string
+		keys string
+		want string
+	}{
+		{"one candidate", "he\t\r", "help "},
+		{"common prefix", "pr\t\r", "print-"},
+		{"no candidates", "x\t\r", "x"},
+		{"second word", "help q\t\r", "help quit "},
+		{"before the cursor", "hx\x02\t\r", "help x"},
+	} {
+		e, _ := newEditor(nil, complete, v.keys)
+		if line, err := e.readLine(); err != nil || line != v.want {
+			t.Errorf("%s: read %q, %v, want %q", v.name, line, err, v.want)
+		}
+	}
+
+	e, out := newEditor(nil, complete, "print-\t")
+	e.readLine()
+	if strings.Contains(out.String(), "print-config  print-work") {
+		t.Errorf("one tab lists the candidates")
+	}
+	e, out = newEditor(nil, complete, "print-\t\t\r")
+	if line, _ := e.readLine(); line != "print-" || !strings.Contains(out.String(), "print-config  print-work") {
+		t.Errorf("read %q, after printing %q", line, out.String())
+	}
+}
+
+func TestCommonPrefix(t *testing.T) {
+	for _, v := range []struct {
+		words []string
+		want  string
+	}{
+		{[]string{"print"}, "print"},
+		{[]string{"print-config", "print-work"}, "print-"},
+		{[]string{"help", "quit"}, ""},
+		{[]string{"héllo", "hèllo"}, "h"},
+		{[]string{"日本", "日本語", "日付"}, "日"},
+	} {
+		if got := commonPrefix(v.words); got != v.want {
+			t.Errorf("commonPrefix(%q) is %q, want %q", v.words, got, v.want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package repl
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+
+	cmd "github.com/iamneal/commander"
+)
+
+// the number of lines kept when no size is given
+const defaultHistorySize = 1000
+
+// history is the lines ran by a REPL, oldest first, kept in a file if there is one
+type history struct {
+	lines []string
+	size  int
+	file  string
+}
+
+// loadHistory returns the most recent size lines of file.
+// The history is usable, and empty, when the file can not be read, a missing file is not an error.
+func loadHistory(file string, size int) (*history, error) {
+	if size < 1 {
+		size = defaultHistorySize
+	}
+	h := &history{size: size}
+	if err := cmd.ReplaceDotSlash(&file); err != nil {
+		return h, err
+	}
+	if err := cmd.ReplaceHome(&file); err != nil {
+		return h, err
+	}
+	h.file = file
+	if file == "" {
+		return h, nil
+	}
+	d, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return h, nil
+	} else if err != nil {
+		return h, err
+	}
+	for _, l := range strings.Split(string(d), "\n") {
+		if l != "" {
+			h.lines = append(h.lines, l)
+		}
+	}
+	if len(h.lines) > size {
+		h.lines = h.lines[len(h.lines)-size:]
+		// rewrite the file, so it does not grow forever
+		return h, cmd.WriteFileAtomic(file, []byte(strings.Join(h.lines, "\n")+"\n"), 0600)
+	}
+	return h, nil
+}
+
+// add appends line to the history, and to the file.
+// A line the same as the one before it is not added again.
+func (h *history) add(line string) error {
+	if n := len(h.lines); n > 0 && h.lines[n-1] == line {
+		return nil
+	}
+	h.lines = append(h.lines, line)
+	if len(h.lines) > h.size {
+		h.lines = h.lines[1:]
+	}
+	if h.file == "" {
+		return nil
+	}
+	f, err := os.OpenFile(h.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(line + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,188 @@
+// Package repl is a terminal head for a commander.Commands.
+// It reads command lines with line editing, history, and tab completion, runs them,
+// and prints the results of their work.
+//
+//	commands := cmd.NewCommands(&config)
+//	if err := repl.New(commands, repl.WithHistoryFile("~/.myapp_history")).Run(context.Background()); err != nil {
+//		log.Fatal(err)
+//	}
+package repl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	cmd "github.com/iamneal/commander"
+)
+
+// REPL reads command lines, and runs them on a Commands
+type REPL struct {
+	cmds        *cmd.Commands
+	prompt      string
+	historyFile string
+	historySize int
+	async       bool
+	// the terminal put in raw mode for line editing, nil reads plain lines
+	term     *os.File
+	complete Completer
+}
+
+// Completer returns the words that can replace the word ending at cursor in line
+type Completer func(line string, cursor int) []string
+
+// Option changes a REPL made by New
+type Option func(*REPL)
+
+// WithPrompt sets the prompt printed before every line, "> " by default
+func WithPrompt(prompt string) Option { return func(r *REPL) { r.prompt = prompt } }
+
+// WithHistoryFile keeps the lines that are ran in path, and loads them when Run starts
+func WithHistoryFile(path string) Option { return func(r *REPL) { r.historyFile = path } }
+
+// WithHistorySize keeps the n most recent lines, 1000 by default
+func WithHistorySize(n int) Option { return func(r *REPL) { r.historySize = n } }
+
+// WithTerminal edits lines on the terminal f, which must be the input of the Commands' IO.
+// A nil f turns line editing off, and lines are read as they are.
+// By default, os.Stdin is used when the Commands' IO is commander.StdIO, and it is a terminal.
+func WithTerminal(f *os.File) Option { return func(r *REPL) { r.term = f } }
+
+// WithAsync prompts for the next line right away, instead of waiting for the work of the last one.
+// Results are printed whenever the work is done.
+func WithAsync() Option { return func(r *REPL) { r.async = true } }
+
+// WithCompleter completes words with c when tab is pressed, instead of DefaultCompleter
+func WithCompleter(c Completer) Option { return func(r *REPL) { r.complete = c } }
+
+// New returns a REPL running command lines on cmds
+func New(cmds *cmd.Commands, opts ...Option) *REPL {
+	r := &REPL{
+		cmds:        cmds,
+		prompt:      "> ",
+		historySize: defaultHistorySize,
+		complete:    DefaultCompleter(cmds),
+	}
+	if cmds.IO() == cmd.StdIO && isTerminal(os.Stdin) {
+		r.term = os.Stdin
+	}
+	for _, o := range opts {
+		o(r)
+	}
+	return r
+}
+
+// DefaultCompleter completes words with the candidates of cmds.Complete
+func DefaultCompleter(cmds *cmd.Commands) Completer {
+	return func(line string, cursor int) (out []string) {
+		for _, v := range cmds.Complete(line, cursor) {
+			out = append(out, v.Value)
+		}
+		return out
+	}
+}
+
+// Run reads lines, and runs them, till the quit command, the end of the input, or ctx is done.
+// Once a line is being read, ctx is only checked after it is.
+// Lines that fail are reported to the Commands' IO, only problems reading lines are returned.
+func (r *REPL) Run(ctx context.Context) error {
+	hist, err := loadHistory(r.historyFile, r.historySize)
+	if err != nil {
+		r.cmds.IO().Eprintf("could not load history from %s: %v\n", r.historyFile, err)
+	}
+	ed := &editor{io: r.cmds.IO(), prompt: r.prompt, history: hist, complete: r.complete}
+	if r.term != nil && r.cmds.Logger() == nil {
+		// the Commands' reports would otherwise be printed over the line being edited
+		r.cmds.SetLogger(ed)
+		defer r.cmds.SetLogger(nil)
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line, err := r.readLine(ed)
+		if err == io.EOF {
+			return nil
+		} else if err == errInterrupt {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if err := hist.add(line); err != nil {
+			r.cmds.IO().Eprintf("could not save history to %s: %v\n", r.historyFile, err)
+		}
+
+		work, err := r.cmds.Run(line)
+		if errors.As(err, &cmd.QuitError{}) {
+			return nil
+		} else if err != nil {
+			r.cmds.IO().Eprintf("%v\n", err)
+			continue
+		}
+		if r.async {
+			go r.report(ctx, ed, work)
+		} else {
+			r.report(ctx, ed, work)
+		}
+	}
+}
+
+// readLine reads a line with the editor when there is a terminal, and as it is otherwise
+func (r *REPL) readLine(ed *editor) (string, error) {
+	if r.term != nil {
+		restore, err := rawMode(r.term)
+		if err == nil {
+			defer restore()
+			return ed.readLine()
+		}
+	}
+	r.cmds.IO().Printf("%s", r.prompt)
+	line, err := r.cmds.IO().In().ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// report waits for work, and for what its action adds to be applied, then prints its error, or its result,
+// through ed, so it is printed above the line being edited.
+// The default actions print their own results, so only their errors are printed.
+func (r *REPL) report(ctx context.Context, ed *editor, work *cmd.Work) {
+	if work == nil || work.WaitApplied(ctx) != nil && ctx.Err() != nil {
+		return
+	}
+	res, err := work.Res()
+	if err != nil {
+		ed.print(true, fmt.Sprintf("%s failed: %v\n", work.Name, err))
+		return
+	}
+	if res == nil || isDefault(r.cmds, work.Name) {
+		return
+	}
+	ed.print(false, cmd.PrettyJson(res))
+}
+
+// isDefault is true when the action named name is one of the default actions
+func isDefault(cmds *cmd.Commands, name string) bool {
+	a, ok := cmds.Snapshot()[name]
+	if !ok {
+		return false
+	}
+	for _, t := range a.Tags() {
+		if t == "default" {
+			return true
+		}
+	}
+	return false
+}
+
+// lastWord returns the word at the end of line, empty if it ends in a space
+func lastWord(line string) string {
+	return line[strings.LastIndexAny(line, " \t")+1:]
+}
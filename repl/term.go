@@ -0,0 +1,34 @@
+package repl
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// isTerminal is true when f is a character device, like a terminal
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// rawMode puts the terminal f in raw mode, and returns a function that puts it back.
+// It uses stty, so it needs no unsafe system calls, and fails where there is no stty.
+func rawMode(f *os.File) (restore func(), err error) {
+	state, err := stty(f, "-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty(f, "raw", "-echo"); err != nil {
+		return nil, err
+	}
+	return func() { stty(f, strings.TrimSpace(state)) }, nil
+}
+
+// stty runs stty with args on the terminal f, and returns what it prints
+func stty(f *os.File, args ...string) (string, error) {
+	c := exec.Command("stty", args...)
+	c.Stdin = f
+	out, err := c.Output()
+	return string(out), err
+}
@@ -0,0 +1,124 @@
+package commander
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// the most suggestions an UnknownCommandError carries
+const maxSuggestions = 5
+
+// UnknownCommandError is returned when no action is stored at Key,
+// and the UnknownCommandHandler gave nothing to run instead
+type UnknownCommandError struct {
+	Key string
+	// the names and aliases Key may have meant, closest first, see Snapshot.Suggest
+	Suggestions []string
+}
+
+func (e UnknownCommandError) Error() string {
+	if len(e.Suggestions) == 0 {
+		return fmt.Sprintf("unknown command: %s", e.Key)
+	}
+	return fmt.Sprintf("unknown command: %s, did you mean %s?", e.Key, strings.Join(e.Suggestions, ", "))
+}
+
+// SuggestUnknownCommand is the UnknownCommandHandler used when none is given.
+// It runs help for an empty key, and makes every other unknown key an UnknownCommandError with suggestions.
+// With autoRun, a key that is the prefix of only one action's names runs that action.
+func SuggestUnknownCommand(autoRun bool) UnknownCommandHandler {
+	return func(c *Commands, key string) Action {
+		snap := c.Snapshot()
+		key = strings.TrimSpace(strings.ToLower(key))
+		if key == "" {
+			return snap["help"]
+		}
+		if !autoRun {
+			return nil
+		}
+		var match Action
+		for k, v := range snap {
+			if !strings.HasPrefix(k, key) {
+				continue
+			}
+			if match != nil && match.Name() != v.Name() {
+				return nil
+			}
+			match = v
+		}
+		if match != nil {
+			c.note("%s is short for %s", key, match.Name())
+		}
+		return match
+	}
+}
+
+// Suggest returns the names and aliases in c that key may have meant, see Snapshot.Suggest
+func (c *Commands) Suggest(key string) []string { return c.Snapshot().Suggest(key) }
+
+// Suggest returns up to 5 of the names and aliases in s that key may have meant.
+// Those starting with key come first, then those a few typos away from it, closest first.
+func (s Snapshot) Suggest(key string) []string {
+	key = strings.TrimSpace(strings.ToLower(key))
+	if key == "" {
+		return nil
+	}
+	type match struct {
+		k    string
+		dist int
+	}
+	var matches []match
+	most := 1 + len(key)/4
+	for k := range s {
+		if strings.HasPrefix(k, key) {
+			matches = append(matches, match{k, -1})
+		} else if d := editDistance(key, k); d <= most {
+			matches = append(matches, match{k, d})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].dist != matches[j].dist {
+			return matches[i].dist < matches[j].dist
+		}
+		return matches[i].k < matches[j].k
+	})
+	var out []string
+	for i := 0; i < len(matches) && i < maxSuggestions; i++ {
+		out = append(out, matches[i].k)
+	}
+	return out
+}
+
+// editDistance is the number of inserted, removed, replaced, or swapped neighbouring runes
+// that turn a into b
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	// three rows of the table, the one two back is needed for swaps
+	prev2, prev, cur := make([]int, len(rb)+1), make([]int, len(rb)+1), make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, minInt(cur[j-1]+1, prev[j-1]+cost))
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = minInt(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(rb)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,65 @@
+package commander
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	for _, v := range []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"help", "help", 0},
+		{"help", "hlp", 1},
+		{"hlp", "help", 1},
+		{"help", "helo", 1},
+		{"help", "hepl", 1},
+		{"ehlp", "help", 1},
+		{"kitten", "sitting", 3},
+		{"save", "load", 4},
+		{"héllo", "hello", 1},
+		{"日本語", "日語本", 1},
+	} {
+		if got := editDistance(v.a, v.b); got != v.want {
+			t.Errorf("editDistance(%q, %q) is %d, want %d", v.a, v.b, got, v.want)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	snap := Snapshot{}
+	for _, k := range []string{"help", "history", "print-config", "print-work", "save", "load", "lookup", "quit"} {
+		snap[k] = Build().WithNameV(k)
+	}
+	for _, v := range []struct {
+		key  string
+		want []string
+	}{
+		{"", nil},
+		{"  ", nil},
+		{"zzz", nil},
+		{"hepl", []string{"help"}},
+		{"HELP", []string{"help"}},
+		{"print", []string{"print-config", "print-work"}},
+		{"prnt-work", []string{"print-work"}},
+		{"h", []string{"help", "history"}},
+		{"lod", []string{"load"}},
+		{"loo", []string{"lookup"}},
+		{"sve", []string{"save"}},
+	} {
+		if got := snap.Suggest(v.key); !reflect.DeepEqual(got, v.want) {
+			t.Errorf("Suggest(%q) is %q, want %q", v.key, got, v.want)
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		snap[string(rune('a'+i))+"x"] = Build()
+	}
+	if got := snap.Suggest("x"); len(got) != maxSuggestions {
+		t.Errorf("%d suggestions for x", len(got))
+	}
+}
@@ -0,0 +1,270 @@
+package commander
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// TypedAction builds an Action whose payload is a P, whose execute works on the C held by the Config,
+// and returns an R. Functions given to it are checked by the compiler, instead of asserting
+// interface{} values at runtime.
+// The Config may hold a *C or a C. When it holds a C, execute is given a pointer to a copy,
+// which is written back to the Config once execute returns, unless the action is read only.
+// Keyed actions, see WithConcurrencyKey, need the Config to hold a *C to not write over each other.
+// A nil Config is given a zero C.
+type TypedAction[C, P, R any] struct {
+	b *builderAction
+}
+
+// NewTypedAction starts a TypedAction named name, with a nop payload and execute.
+// Replayed payloads are converted back to a P with a json round trip.
+func NewTypedAction[C, P, R any](name string) *TypedAction[C, P, R] {
+	t := &TypedAction[C, P, R]{b: Build().WithNameV(name).WithDescV("")}
+	t.b.WithReplayPayload(func(_ *Config, payload interface{}) (interface{}, error) {
+		if p, ok := payload.(P); ok || payload == nil {
+			return p, nil
+		}
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		var p P
+		err = json.Unmarshal(b, &p)
+		return p, err
+	})
+	return t
+}
+
+// Action returns the Action that was built
+func (t *TypedAction[C, P, R]) Action() Action { return t.b }
+
+// WithDesc sets the action's description.
+// it returns itself for chaining.
+func (t *TypedAction[C, P, R]) WithDesc(desc string) *TypedAction[C, P, R] {
+	t.b.WithDescV(desc)
+	return t
+}
+
+// WithMeta documents the action with "m", see Describer.
+// it returns itself for chaining.
+func (t *TypedAction[C, P, R]) WithMeta(m Meta) *TypedAction[C, P, R] {
+	t.b.WithMeta(m)
+	return t
+}
+
+// WithTags sets the action's tags.
+// it returns itself for chaining.
+func (t *TypedAction[C, P, R]) WithTags(tags ...string) *TypedAction[C, P, R] {
+	t.b.WithTagsV(tags...)
+	return t
+}
+
+// WithPayload builds the action's payload with "p".
+// it returns itself for chaining.
+func (t *TypedAction[C, P, R]) WithPayload(p func(*C) (P, error)) *TypedAction[C, P, R] {
+	return t.WithIOPayload(func(c *C, _ *IO) (P, error) { return p(c) })
+}
+
+// WithPayloadV always uses "p" as the action's payload.
+// it returns itself for chaining.
+func (t *TypedAction[C, P, R]) WithPayloadV(p P) *TypedAction[C, P, R] {
+	return t.WithPayload(func(*C) (P, error) { return p, nil })
+}
+
+// WithIOPayload builds the action's payload with "p", given the IO of the Commands running it.
+// it returns itself for chaining.
+func (t *TypedAction[C, P, R]) WithIOPayload(p func(*C, *IO) (P, error)) *TypedAction[C, P, R] {
+	t.b.WithIOPayload(func(conf *Config, io *IO) (interface{}, error) {
+		c, _, err := typedConfig[C](conf)
+		if err != nil {
+			return nil, err
+		}
+		return p(c, io)
+	})
+	return t
+}
+
+// WithArgsPayload builds the action's payload from command line arguments with "a".
+// it returns itself for chaining.
+func (t *TypedAction[C, P, R]) WithArgsPayload(a func(*C, []string) (P, error)) *TypedAction[C, P, R] {
+	t.b.WithArgsPayload(func(conf *Config, args []string) (interface{}, error) {
+		c, _, err := typedConfig[C](conf)
+		if err != nil {
+			return nil, err
+		}
+		return a(c, args)
+	})
+	return t
+}
+
+// WithExecute runs "e" as the action's execute.
+// it returns itself for chaining.
+func (t *TypedAction[C, P, R]) WithExecute(e func(*C, P) (R, error)) *TypedAction[C, P, R] {
+	return t.WithExecuteCtx(func(_ context.Context, c *C, p P) (R, error) { return e(c, p) })
+}
+
+// WithExecuteCtx runs "e" as the action's execute, with a context that is canceled along with its work.
+// it returns itself for chaining.
+func (t *TypedAction[C, P, R]) WithExecuteCtx(e func(context.Context, *C, P) (R, error)) *TypedAction[C, P, R] {
+	t.b.WithExecuteCtx(func(ctx context.Context, conf *Config, payload interface{}) (interface{}, error) {
+		p, ok := payload.(P)
+		if !ok && payload != nil {
+			return nil, TypeConvertErr(payload, p)
+		}
+		c, save, err := typedConfig[C](conf)
+		if err != nil {
+			return nil, err
+		}
+		if !t.b.readOnly {
+			defer save()
+		}
+		return e(ctx, c, p)
+	})
+	return t
+}
+
+// WithAdditions adds the actions "a" returns once the action's work succeeds.
+// it returns itself for chaining.
+func (t *TypedAction[C, P, R]) WithAdditions(a func(*C) map[string]Action) *TypedAction[C, P, R] {
+	t.b.WithAdditions(func(conf *Config) map[string]Action {
+		c, _, err := typedConfig[C](conf)
+		if err != nil {
+			return nil
+		}
+		return a(c)
+	})
+	return t
+}
+
+// WithRemovals removes the actions named names once the action's work succeeds.
+// it returns itself for chaining.
+func (t *TypedAction[C, P, R]) WithRemovals(names ...string) *TypedAction[C, P, R] {
+	t.b.WithRemovals(func() []string { return names })
+	return t
+}
+
+// WithConcurrencyKey lets the action's work run alongside work with other keys, see ConcurrencyKeyer.
+// it returns itself for chaining.
+func (t *TypedAction[C, P, R]) WithConcurrencyKey(key string) *TypedAction[C, P, R] {
+	t.b.WithConcurrencyKey(key)
+	return t
+}
+
+// WithReadOnly declares that the action's execute only reads the Config, see ExecuteReadOnly.
+// it returns itself for chaining.
+func (t *TypedAction[C, P, R]) WithReadOnly(readOnly bool) *TypedAction[C, P, R] {
+	t.b.WithReadOnly(readOnly)
+	return t
+}
+
+// typedConfig returns the C held by conf.
+// When conf holds a C, not a *C, the pointer is to a copy, and save writes the copy back to conf.
+// A nil conf is treated as holding a zero C.
+func typedConfig[C any](conf *Config) (c *C, save func(), err error) {
+	switch v := (*conf).(type) {
+	case *C:
+		return v, func() {}, nil
+	case C:
+		c = &v
+	case nil:
+		c = new(C)
+	default:
+		return nil, nil, TypeConvertErr(*conf, c)
+	}
+	return c, func() { *conf = *c }, nil
+}
+
+// StatePayload adapts a payload function of the *T held by the Config to a Payload
+func StatePayload[T any](p func(*T) (interface{}, error)) Payload {
+	return func(conf *Config) (interface{}, error) {
+		t, _, err := typedConfig[T](conf)
+		if err != nil {
+			return nil, err
+		}
+		return p(t)
+	}
+}
+
+// StateExecute adapts an execute function of the *T held by the Config to an Execute.
+// When the Config holds a T, not a *T, a copy is given to "e" and written back after,
+// so read only and keyed actions need the Config to hold a *T.
+func StateExecute[T any](e func(*T, interface{}) (interface{}, error)) Execute {
+	return func(conf *Config, payload interface{}) (interface{}, error) {
+		t, save, err := typedConfig[T](conf)
+		if err != nil {
+			return nil, err
+		}
+		defer save()
+		return e(t, payload)
+	}
+}
+
+// StateAdditions adapts an additions function of the *T held by the Config to Additions
+func StateAdditions[T any](a func(*T) map[string]Action) Additions {
+	return func(conf *Config) map[string]Action {
+		t, _, err := typedConfig[T](conf)
+		if err != nil {
+			return nil
+		}
+		return a(t)
+	}
+}
+
+// StateAction is an Action whose Payload, Execute, and Additions are given the *T held by the Config,
+// instead of the Config itself. FromState adapts it to an Action.
+type StateAction[T any] interface {
+	Payload(*T) (interface{}, error)
+	Execute(*T, interface{}) (interface{}, error)
+	Additions(*T) map[string]Action
+	Removals() []string
+	Name() string
+	Desc() string
+	Tags() []string
+}
+
+// FromState adapts a to an Action, see StateExecute
+func FromState[T any](a StateAction[T]) Action {
+	return Build().
+		WithName(a.Name).
+		WithDesc(a.Desc).
+		WithTags(a.Tags).
+		WithRemovals(a.Removals).
+		WithPayload(StatePayload(a.Payload)).
+		WithExecute(StateExecute(a.Execute)).
+		WithAdditions(StateAdditions(a.Additions))
+}
+
+// TypedCommands is a Commands whose Config holds a *T.
+// Actions built with NewTypedAction[T, P, R], FromState, or the State adapters are given the *T directly.
+type TypedCommands[T any] struct {
+	*Commands
+}
+
+// NewTypedCommands returns a Commands working on state, a nil state starts as a zero T
+func NewTypedCommands[T any](state *T, opts ...opt) *TypedCommands[T] {
+	return NewTypedCommandsWithIO(state, nil, opts...)
+}
+
+// NewTypedCommandsWithIO is NewTypedCommands, but prompts and prints through io, see NewCommandsWithIO
+func NewTypedCommandsWithIO[T any](state *T, io *IO, opts ...opt) *TypedCommands[T] {
+	if state == nil {
+		state = new(T)
+	}
+	var conf Config = state
+	// the factory comes first, so options restoring the Config use it
+	opts = append([]opt{WithFactory(NewFactory[*T]())}, opts...)
+	return &TypedCommands[T]{Commands: NewCommandsWithIO(&conf, io, opts...)}
+}
+
+// State returns the *T held by the Config.
+// Undo and load replace it, so it should not be kept around.
+// It is not synchronized with running work, read it from actions, or once the work is done.
+func (c *TypedCommands[T]) State() *T {
+	t, _, _ := typedConfig[T](c.conf)
+	return t
+}
+
+// SetState adapts a with FromState, and sets it, see Commands.Set
+func (c *TypedCommands[T]) SetState(a StateAction[T], additionalKeys ...string) {
+	c.Set(FromState(a), additionalKeys...)
+}
@@ -2,21 +2,60 @@ package commander
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
-// opt is a key and value pair where the value is overridable
+// opt is a key and value pair where the value is overridable.
+// Opts made by the With functions, like WithIO, may also change the Commands directly, see options.go
 type opt struct {
 	key   string
 	value string
+	// called with the Commands once its default actions are set, if not nil
+	apply func(*Commands)
 }
 
 func OptZero(key string) opt         { return opt{key: key} }
-func Opt(key, value string) opt      { return opt{key, value} }
+func Opt(key, value string) opt      { return opt{key: key, value: value} }
 func (o opt) Set(v string) opt       { return opt{key: o.key, value: v} }
 func (o opt) Read() (string, string) { return o.key, o.value }
 
+// the keys of the opts read by Commands
+const (
+	// the number of work that can be queued before queueing blocks, defaults to 10.
+	// Values that are not numbers are the default, and those below 1 are 1.
+	OptQueueSize = "queue-size"
+	// when "false", the default actions are not set
+	OptDefaults = "defaults"
+	// the number of work that can run at the same time, defaults to 1, as do values below 1 and those that are not numbers.
+	// Only work of actions that implement ConcurrencyKeyer can share the workers.
+	OptWorkers = "workers"
+	// the number of work History keeps of each action, defaults to 10, as do values below 1 and those that are not numbers
+	OptActionHistory = "action-history"
+	// the number of work History keeps of all actions, defaults to 100, as do values below 1 and those that are not numbers
+	OptTimelineHistory = "timeline-history"
+	// when "true", a panicking job is not recovered into a PanicError, and crashes the program.
+	// Useful for debugging.
+	OptPropagatePanics = "propagate-panics"
+	// the file to autosave the Config to. When given, the Config is restored from it,
+	// or from its backups, and saved after every work that changes it, see Commands.EnableAutosave
+	OptAutosave = "autosave"
+	// the number of previous autosaves to keep, defaults to 0
+	OptAutosaveBackups = "autosave-backups"
+	// when given, a duration like "30s", the Config is autosaved that often instead of after every work
+	OptAutosaveInterval = "autosave-interval"
+	// a comma separated list of registered libraries to Use, see Register
+	OptUse = "use"
+)
+
 // the error returned whenever Commands.Get("quit")() is called
 var Quit = QuitError{}
 
@@ -26,6 +65,26 @@ func (QuitError) Error() string {
 	return "quit"
 }
 
+// the error returned when work is given to Commands after Commands.Shutdown is called
+var Stopped = StoppedError{}
+
+type StoppedError struct{}
+
+func (StoppedError) Error() string {
+	return "stopped"
+}
+
+// PanicError is the error of work whose job panicked.
+// Value is what was given to panic, and Stack is the stack trace of the goroutine that panicked.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (p PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", p.Value)
+}
+
 // the error to return from a payload function if you would like
 // to skip the execution function of the action
 var Skip = SkipExecute{}
@@ -36,6 +95,16 @@ func (SkipExecute) Error() string {
 	return "skip"
 }
 
+// the error to return from an ArgsPayload function if the action
+// does not take command line arguments. The interactive payload is used instead
+var NoArgs = NoArgsError{}
+
+type NoArgsError struct{}
+
+func (NoArgsError) Error() string {
+	return "no args"
+}
+
 // Could be anything, but helps distinguish between payload
 // which is less significant
 type Config interface{}
@@ -44,28 +113,75 @@ type actionParts struct {
 	name      Name
 	desc      Desc
 	payload   Payload
+	iopayload IOPayload
+	args      ArgsPayload
+	ioargs    IOArgsPayload
+	replay    ReplayPayload
 	execute   Execute
 	additions Additions
 	removals  Removals
 	tags      Tags
+	key       string
+	readOnly  bool
+	ctx       ExecuteCtx
+	meta      Meta
+	complete  Complete
 }
 
 func (a actionParts) Name() Name           { return a.name }
 func (a actionParts) Desc() Desc           { return a.desc }
 func (a actionParts) Payload() Payload     { return a.payload }
+func (a actionParts) IOPayload() IOPayload { return a.iopayload }
+func (a actionParts) ArgsPayload() ArgsPayload {
+	if a.args == nil {
+		return NewArgsPayload()
+	}
+	return a.args
+}
+func (a actionParts) IOArgsPayload() IOArgsPayload { return a.ioargs }
+func (a actionParts) ExecuteCtx() ExecuteCtx {
+	if a.ctx == nil {
+		return a.execute.Ctx()
+	}
+	return a.ctx
+}
 func (a actionParts) Execute() Execute     { return a.execute }
 func (a actionParts) Additions() Additions { return a.additions }
 func (a actionParts) Removals() Removals   { return a.removals }
 func (a actionParts) Tags() Tags           { return a.tags }
+func (a actionParts) Meta() Meta           { return a.meta }
+func (a actionParts) Complete() Complete   { return a.complete }
 func (a actionParts) Action() Action {
-	return Build().
+	b := Build().
 		WithName(a.name).
 		WithDesc(a.desc).
 		WithPayload(a.payload).
 		WithExecute(a.execute).
 		WithAdditions(a.additions).
 		WithRemovals(a.removals).
-		WithTags(a.tags)
+		WithTags(a.tags).
+		WithConcurrencyKey(a.key).
+		WithReadOnly(a.readOnly).
+		WithMeta(a.meta)
+	if a.iopayload != nil {
+		b.WithIOPayload(a.iopayload)
+	}
+	if a.args != nil {
+		b.WithArgsPayload(a.args)
+	}
+	if a.ioargs != nil {
+		b.WithIOArgsPayload(a.ioargs)
+	}
+	if a.ctx != nil {
+		b.WithExecuteCtx(a.ctx)
+	}
+	if a.replay != nil {
+		b.WithReplayPayload(a.replay)
+	}
+	if a.complete != nil {
+		b.WithComplete(a.complete)
+	}
+	return b
 }
 
 // NopParts returns a new actionParts struct without having to provide an action
@@ -76,7 +192,52 @@ func NopParts() actionParts {
 // Break returns a struct that can access the individual Action functions as
 // Generic Versions  of the interface{} they are each supposed to implement
 func Break(action Action) actionParts {
+	var iopayload IOPayload
+	if p, ok := action.(IOPayloader); ok {
+		iopayload = p.IOPayload
+	}
+	var args ArgsPayload
+	if p, ok := action.(ArgsPayloader); ok {
+		args = p.ArgsPayload
+	}
+	var ioargs IOArgsPayload
+	if p, ok := action.(IOArgsPayloader); ok {
+		ioargs = p.IOArgsPayload
+	}
+	var key string
+	if k, ok := action.(ConcurrencyKeyer); ok {
+		key = k.ConcurrencyKey()
+	}
+	var readOnly bool
+	if r, ok := action.(ExecuteReadOnly); ok {
+		readOnly = r.ReadOnly()
+	}
+	var ctx ExecuteCtx
+	if e, ok := action.(ContextExecuter); ok {
+		ctx = e.ExecuteCtx
+	}
+	var replay ReplayPayload
+	if r, ok := action.(ReplayPayloader); ok {
+		replay = r.ReplayPayload
+	}
+	var meta Meta
+	if d, ok := action.(Describer); ok {
+		meta = d.Meta()
+	}
+	var complete Complete
+	if c, ok := action.(Completer); ok {
+		complete = c.Complete
+	}
 	return actionParts{
+		complete:  complete,
+		meta:      meta,
+		replay:    replay,
+		ctx:       ctx,
+		key:       key,
+		readOnly:  readOnly,
+		iopayload: iopayload,
+		args:      args,
+		ioargs:    ioargs,
 		name:      func() string { return action.Name() },
 		desc:      func() string { return action.Desc() },
 		tags:      func() []string { return action.Tags() },
@@ -114,6 +275,19 @@ func (e Execute) FromE(f interface{}, err error) Execute {
 // where <f> is the result of some Execute function
 func (e Execute) From(f interface{}) Execute { return e.FromE(f, nil) }
 
+// Ctx returns an ExecuteCtx that ignores the context it is given and calls e
+func (e Execute) Ctx() ExecuteCtx {
+	return func(_ context.Context, c *Config, p interface{}) (interface{}, error) { return e(c, p) }
+}
+
+// the function signiture of the ContextExecuter.ExecuteCtx function
+type ExecuteCtx func(context.Context, *Config, interface{}) (interface{}, error)
+
+// Execute returns an Execute that calls e with context.Background()
+func (e ExecuteCtx) Execute() Execute {
+	return func(c *Config, p interface{}) (interface{}, error) { return e(context.Background(), c, p) }
+}
+
 // Chain is used to merge together Execute functions.
 // var ein interface{}
 // var e Execute
@@ -146,6 +320,46 @@ func (p Payload) FromE(q interface{}, err error) Payload {
 }
 func (p Payload) From(q interface{}) Payload { return p.FromE(q, nil) }
 
+// IO returns an IOPayload that ignores the IO it is given and calls p
+func (p Payload) IO() IOPayload {
+	return func(c *Config, _ *IO) (interface{}, error) { return p(c) }
+}
+
+// the function signiture of the IOPayloader.IOPayload function
+type IOPayload func(*Config, *IO) (interface{}, error)
+
+// Payload returns a Payload that calls i with StdIO
+func (i IOPayload) Payload() Payload {
+	return func(c *Config) (interface{}, error) { return i(c, StdIO) }
+}
+
+// the function signiture of the Completer.Complete function
+type Complete func(*Config, []string) []string
+
+// the function signiture of the ArgsPayloader.ArgsPayload function
+type ArgsPayload func(*Config, []string) (interface{}, error)
+
+func (a ArgsPayload) FromE(q interface{}, err error) ArgsPayload {
+	return func(*Config, []string) (interface{}, error) { return q, err }
+}
+func (a ArgsPayload) From(q interface{}) ArgsPayload { return a.FromE(q, nil) }
+
+// the function signiture of the ReplayPayloader.ReplayPayload function
+type ReplayPayload func(*Config, interface{}) (interface{}, error)
+
+// IO returns an IOArgsPayload that ignores the IO it is given and calls a
+func (a ArgsPayload) IO() IOArgsPayload {
+	return func(c *Config, _ *IO, args []string) (interface{}, error) { return a(c, args) }
+}
+
+// the function signiture of the IOArgsPayloader.IOArgsPayload function
+type IOArgsPayload func(*Config, *IO, []string) (interface{}, error)
+
+// ArgsPayload returns an ArgsPayload that calls i with StdIO
+func (i IOArgsPayload) ArgsPayload() ArgsPayload {
+	return func(c *Config, args []string) (interface{}, error) { return i(c, StdIO, args) }
+}
+
 // Returns a new Payload composed of p and all of the Payloads in qs (q).
 // The result of the returned Payload will always be []interface{}.
 // If the results of p or q are already []inteface{},
@@ -205,7 +419,7 @@ func (p Payload) ChainMap(qs ...Payload) Payload {
 					res[k] = v
 				}
 			} else {
-				res[string(i)] = qres
+				res[strconv.Itoa(i)] = qres
 			}
 		}
 		return res, nil
@@ -232,12 +446,24 @@ type Tags func() []string
 // KV.Q is the question that will be given to scan.
 // KV.Key is the key field, or variable name, that was scanned.
 // KV.Hint is the type of variable that to the type that needs to be.
-// STR = string, INT = int64, FLO = float64
+// STR = string, INT = int64, FLO = float64, BOOL = bool, DUR = time.Duration,
+// CHOICE = string that is one of KV.Choices, LIST = []string split on commas,
+// PATH = string path to a file that exists, JSON = the decoded interface{} of a json value
+// A KV is also a command line flag, it can be given as --Key=value, --Key value,
+// or as -Short value when KV.Short is set.
+// KV.Required KVs are asked for when they are not given as flags, other KVs use KV.Default.
+// Answers must pass every one of KV.Validators, and a bad answer is asked for again,
+// up to KV.Retries times.
 type KV struct {
-	Q       string
-	Key     string
-	Hint    ScanType
-	Default interface{}
+	Q          string
+	Key        string
+	Short      string
+	Hint       ScanType
+	Default    interface{}
+	Required   bool
+	Choices    []string
+	Validators []Validator
+	Retries    int
 }
 
 // NewKV returns a  new KV struct. <hint> will be the type of value used.
@@ -254,6 +480,14 @@ func NewKV(q, key string, hint ScanType) KV {
 		kv.Default = int64(0)
 	case FLO:
 		kv.Default = float64(0)
+	case BOOL:
+		kv.Default = false
+	case DUR:
+		kv.Default = time.Duration(0)
+	case CHOICE, PATH:
+		kv.Default = ""
+	case LIST:
+		kv.Default = []string{}
 	}
 	return kv
 }
@@ -264,35 +498,241 @@ func (q KV) WithDefault(def interface{}) KV {
 	return q
 }
 
-// Scan returns the key, value, and any error encountered during fmt.Scanln of user's input
-func (q KV) Scan() (string, interface{}, error) {
-	// return the result if err is nil, otherwise return the default
-	handleScan := func(res interface{}, err error) (interface{}, error) {
-		if err != nil {
-			return q.Default, err
+// WithShort returns this KV struct, but it can also be given on the command line as -short
+func (q KV) WithShort(short string) KV {
+	q.Short = short
+	return q
+}
+
+// WithRequired returns this KV struct, but a KV that is required must be answered,
+// and is asked for when it is not given as a flag
+func (q KV) WithRequired(required bool) KV {
+	q.Required = required
+	return q
+}
+
+// WithChoices returns this KV struct, but with the answers a CHOICE KV accepts
+func (q KV) WithChoices(choices ...string) KV {
+	q.Choices = choices
+	return q
+}
+
+// WithValidator returns this KV struct, but answers must also pass v
+func (q KV) WithValidator(v Validator) KV {
+	q.Validators = append(append([]Validator{}, q.Validators...), v)
+	return q
+}
+
+// WithRetries returns this KV struct, but a bad answer is asked for up to n times.
+// n less than 1 uses the default of 3.
+func (q KV) WithRetries(n int) KV {
+	q.Retries = n
+	return q
+}
+
+// Validate returns the first error from q's Validators for v
+func (q KV) Validate(v interface{}) error {
+	for _, f := range q.Validators {
+		if err := f(v); err != nil {
+			return fmt.Errorf("%s: %v", q.Key, err)
 		}
-		return res, err
 	}
-	var val interface{}
-	var err error
+	return nil
+}
+
+// parseValid is Parse followed by Validate
+func (q KV) parseValid(s string) (interface{}, error) {
+	v, err := q.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	return v, q.Validate(v)
+}
+
+// coerceValid is Coerce followed by Validate, a Required q must be given, nil is its Default otherwise
+func (q KV) coerceValid(v interface{}) (interface{}, error) {
+	if v == nil {
+		if q.Required {
+			return nil, fmt.Errorf("%s is required", q.Key)
+		}
+		return q.Default, nil
+	}
+	v, err := q.Coerce(v)
+	if err != nil {
+		return nil, err
+	}
+	return v, q.Validate(v)
+}
+
+// Parse converts s into the type of value q.Hint describes,
+// and returns an error if s is not a valid value of that type
+func (q KV) Parse(s string) (interface{}, error) {
 	switch q.Hint {
 	case STR:
-		p := ""
-		err := scan(q.Q, &p)
-		val, err = handleScan(p, err)
+		return s, nil
 	case INT:
-		p := int64(0)
-		err := scan(q.Q, &p)
-		val, err = handleScan(p, err)
+		return strconv.ParseInt(s, 10, 64)
 	case FLO:
-		p := float64(0)
-		err := scan(q.Q, &p)
-		val, err = handleScan(p, err)
+		return strconv.ParseFloat(s, 64)
+	case BOOL:
+		switch strings.ToLower(s) {
+		case "y", "yes", "t", "true", "1":
+			return true, nil
+		case "n", "no", "f", "false", "0":
+			return false, nil
+		}
+		return nil, fmt.Errorf("%q is not yes or no", s)
+	case DUR:
+		return time.ParseDuration(s)
+	case CHOICE:
+		for _, v := range q.Choices {
+			if v == s {
+				return s, nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not one of: %s", s, strings.Join(q.Choices, ", "))
+	case LIST:
+		out := []string{}
+		for _, v := range strings.Split(s, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				out = append(out, v)
+			}
+		}
+		return out, nil
+	case PATH:
+		if err := expandPath(&s); err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(s); err != nil {
+			return nil, err
+		}
+		return s, nil
+	case JSON:
+		var out interface{}
+		if err := json.Unmarshal([]byte(s), &out); err != nil {
+			return nil, fmt.Errorf("invalid json: %v", err)
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("unknown scan type %d for %s", q.Hint, q.Key)
+}
+
+// Coerce converts v, an answer to q that may have lost its type in an encoding like json,
+// back into the type KV.Parse returns for q.Hint. Strings are given to KV.Parse,
+// and nil is the KV's Default. An INT answer must be a whole number.
+func (q KV) Coerce(v interface{}) (interface{}, error) {
+	if v == nil {
+		return q.Default, nil
+	}
+	if s, ok := v.(string); ok && q.Hint != JSON {
+		return q.Parse(s)
+	}
+	switch q.Hint {
+	case INT:
+		switch t := v.(type) {
+		case int64:
+			return t, nil
+		case float64:
+			if t != math.Trunc(t) {
+				return nil, fmt.Errorf("can not use %v as the answer to %s, it is not a whole number", t, q.Key)
+			}
+			return int64(t), nil
+		}
+	case FLO:
+		if t, ok := v.(float64); ok {
+			return t, nil
+		}
+	case BOOL:
+		if t, ok := v.(bool); ok {
+			return t, nil
+		}
+	case DUR:
+		switch t := v.(type) {
+		case time.Duration:
+			return t, nil
+		case float64:
+			return time.Duration(t), nil
+		}
+	case LIST:
+		switch t := v.(type) {
+		case []string:
+			return t, nil
+		case []interface{}:
+			out := []string{}
+			for _, i := range t {
+				out = append(out, fmt.Sprint(i))
+			}
+			return out, nil
+		}
+	case JSON:
+		return v, nil
+	}
+	return nil, fmt.Errorf("can not use %#v (%T) as the answer to %s", v, v, q.Key)
+}
+
+// question is KV.Q with a hint of the answers it accepts
+func (q KV) question() string {
+	switch q.Hint {
+	case BOOL:
+		return q.Q + " (y/n)"
+	case CHOICE:
+		return q.Q + " [" + strings.Join(q.Choices, "|") + "]"
+	case LIST:
+		return q.Q + " (separated by commas)"
+	}
+	return q.Q
+}
+
+// the number of times KV.Scan asks the question before giving up on bad answers
+// when KV.Retries is not set
+const scanRetries = 3
+
+func (q KV) retries() int {
+	if q.Retries < 1 {
+		return scanRetries
+	}
+	return q.Retries
+}
+
+// Scan returns the key, value, and any error encountered scanning the user's input from io.
+// A nil io scans from StdIO.
+// An empty answer is the KV's Default, unless the KV is Required.
+// Answers that Parse or Validate reject are reported to io's Err, and the question is asked again.
+func (q KV) Scan(io *IO) (string, interface{}, error) {
+	val := q.Default
+	var scanErr error
+	err := retry(q.retries(), func() error {
+		var answer string
+		// errors reading are not retried
+		if scanErr = io.Scan(q.question(), &answer); scanErr != nil {
+			return nil
+		}
+		if answer == "" {
+			if q.Required {
+				err := fmt.Errorf("%s is required", q.Key)
+				io.Eprintf("%v\n", err)
+				return err
+			}
+			return nil
+		}
+		v, err := q.parseValid(answer)
+		if err != nil {
+			io.Eprintf("%v\n", err)
+			return err
+		}
+		val = v
+		return nil
+	})
+	if scanErr != nil {
+		return q.Key, q.Default, scanErr
+	}
+	if err != nil {
+		return q.Key, q.Default, err
 	}
-	return q.Key, val, err
+	return q.Key, val, nil
 }
-func (q KV) MustScan() (string, interface{}) {
-	if k, v, err := q.Scan(); err != nil {
+func (q KV) MustScan(io *IO) (string, interface{}) {
+	if k, v, err := q.Scan(io); err != nil {
 		panic("error scanning a value that must be scanned: " + err.Error())
 	} else {
 		return k, v
@@ -305,8 +745,38 @@ const (
 	STR ScanType = iota
 	INT
 	FLO
+	BOOL
+	DUR
+	CHOICE
+	LIST
+	PATH
+	JSON
 )
 
+func (s ScanType) String() string {
+	switch s {
+	case STR:
+		return "string"
+	case INT:
+		return "int"
+	case FLO:
+		return "float"
+	case BOOL:
+		return "bool"
+	case DUR:
+		return "duration"
+	case CHOICE:
+		return "choice"
+	case LIST:
+		return "list"
+	case PATH:
+		return "path"
+	case JSON:
+		return "json"
+	}
+	return "ScanType(" + strconv.Itoa(int(s)) + ")"
+}
+
 // Adds this kv to the map parameter
 func (q KV) AddTo(m map[string]KV) { m[q.Key] = q }
 
@@ -321,13 +791,35 @@ func MapKVs(kvs ...KV) map[string]KV {
 
 type Work struct {
 	Name    string
-	job     Execute
+	job     ExecuteCtx
 	payload interface{}
 	wait    chan struct{}
+	// work with the same key runs in order, "" needs the Config to itself
+	key string
+	// needs the Config to itself even with a key, its hooks read all of it
+	alone bool
+	// given to the job, canceled by Cancel
+	ctx    context.Context
+	cancel context.CancelFunc
+	// called with the Config right before the job, if not nil
+	before func(*Config)
+	// called with the Config right after the job, if it succeeded and is not nil
+	after func(*Config)
+	// closed once the additions and removals of the work's action are applied, nil when it was not dispatched
+	applied <-chan struct{}
+	// guards started, done, stopping, unqueue, and the populated fields below
+	mu      sync.Mutex
+	started bool
+	done    bool
+	// Cancel was called while the job was running
+	stopping bool
+	// takes the work off the queue it is waiting in, nil when it was not queued
+	unqueue func()
 	// job     func(*Config, interface{}) (interface{}, error)
 	// only populated after Do is called on the result
 	Success    bool
 	Failure    bool
+	Cancelled  bool
 	Result     interface{}
 	err        error
 	FinishedAt time.Time
@@ -335,11 +827,23 @@ type Work struct {
 }
 
 func workFromAction(a Action, payload interface{}) *Work {
+	var key string
+	if k, ok := a.(ConcurrencyKeyer); ok {
+		key = k.ConcurrencyKey()
+	}
+	job := Execute(a.Execute).Ctx()
+	if e, ok := a.(ContextExecuter); ok {
+		job = e.ExecuteCtx
+	}
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Work{
 		Name:      a.Name(),
-		job:       a.Execute,
+		job:       job,
 		payload:   payload,
 		wait:      make(chan struct{}, 1),
+		key:       key,
+		ctx:       ctx,
+		cancel:    cancel,
 		CreatedAt: time.Now(),
 	}
 }
@@ -353,17 +857,149 @@ func (w *Work) Wait(ctx context.Context) error {
 	}
 }
 
+// WaitApplied waits for the work to finish, like Wait, and then for the additions and removals of its action
+// to be applied, and for the work to be journaled and reported, so a line ran next can use what it added.
+func (w *Work) WaitApplied(ctx context.Context) error {
+	if err := w.Wait(ctx); err != nil || w.applied == nil {
+		return err
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-w.applied:
+		return nil
+	}
+}
+
+// Done reports whether the work has finished, without blocking
+func (w *Work) Done() bool {
+	select {
+	case <-w.wait:
+		return true
+	default:
+		return false
+	}
+}
+
+// Cancel cancels the context given to the work's job.
+// Work that has not started yet will never start, and finishes right away, Cancelled, with context.Canceled.
+// Running work finishes when its job returns, and is only Cancelled if the job returns the context's error,
+// jobs that are not given a context can not be stopped, and finish as they would have.
+// It returns false if the work had already finished.
+func (w *Work) Cancel() bool {
+	w.mu.Lock()
+	if w.done {
+		w.mu.Unlock()
+		return false
+	}
+	started, unqueue := w.started, w.unqueue
+	if started {
+		w.stopping = true
+	} else {
+		w.Cancelled = true
+	}
+	w.mu.Unlock()
+
+	w.cancel()
+	if !started {
+		w.finish(nil, context.Canceled)
+		// it will never run, so it does not keep room in the queue
+		if unqueue != nil {
+			unqueue()
+		}
+	}
+	return true
+}
+
 func (w *Work) Res() (interface{}, error) {
 	return w.Result, w.err
 }
 
-func (w *Work) do(conf *Config) error {
+// MarshalJSON encodes the exported fields of the work, copied while it is locked,
+// so work can be printed, or journaled, while it may still be running
+func (w *Work) MarshalJSON() ([]byte, error) {
+	type work struct {
+		Name       string
+		Success    bool
+		Failure    bool
+		Cancelled  bool
+		Result     interface{}
+		FinishedAt time.Time
+		CreatedAt  time.Time
+	}
+	w.mu.Lock()
+	c := work{w.Name, w.Success, w.Failure, w.Cancelled, w.Result, w.FinishedAt, w.CreatedAt}
+	w.mu.Unlock()
+	return json.Marshal(c)
+}
+
+// do runs the job, a panic in the job fails the work with a PanicError,
+// unless propagate is true
+func (w *Work) do(conf *Config, propagate bool) error {
+	w.mu.Lock()
+	if w.done || w.Cancelled {
+		w.mu.Unlock()
+		return context.Canceled
+	}
+	w.started = true
+	w.mu.Unlock()
+
+	if w.before != nil {
+		w.before(conf)
+	}
+	var res interface{}
+	var err error
+	if propagate {
+		res, err = w.job(w.ctx, conf, w.payload)
+	} else {
+		res, err = w.recoverJob(conf)
+	}
+	if w.after != nil && err == nil {
+		w.after(conf)
+	}
+	return w.finish(res, err)
+}
+
+// recoverJob runs the job, and returns a PanicError if it panics
+func (w *Work) recoverJob(conf *Config) (res interface{}, err error) {
 	defer func() {
+		if r := recover(); r != nil {
+			res, err = nil, PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return w.job(w.ctx, conf, w.payload)
+}
+
+// skip releases everything waiting on work that will never be ran
+func (w *Work) skip() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.done {
+		return
+	}
+	w.done = true
+	w.cancel()
+	close(w.wait)
+}
+
+// finish populates the result of the work, and releases everything waiting on it.
+// Only the first call does anything.
+func (w *Work) finish(res interface{}, err error) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.done {
+		return w.err
+	}
+	w.done = true
+	defer func() {
+		w.cancel()
 		close(w.wait)
 	}()
 
-	res, err := w.job(conf, w.payload)
 	w.FinishedAt = time.Now()
+	if w.stopping && errors.Is(err, context.Canceled) {
+		w.Cancelled = true
+	}
 	if err != nil {
 		w.Success = false
 		w.Failure = true
@@ -380,35 +1016,314 @@ func (w *Work) do(conf *Config) error {
 }
 
 type workChan struct {
-	CachedResults map[string]*Work
-	queue         chan *Work
+	// every work that has been started
+	history *History
+	// guards active and lastByKey, written by every worker
+	mu sync.Mutex
+	// work that has been queued, and may not be finished
+	active map[*Work]struct{}
+	// the last work started with each key, till it is done
+	lastByKey map[string]*Work
+	// work without a concurrency key holds the write lock, keyed and read only work hold the read lock
+	conf sync.RWMutex
+	// keyed work writes to the Config alongside other keyed work, so it is kept apart from read only work
+	gate gate
+	// a slot for every worker
+	workers chan struct{}
+	// let panics in jobs crash the worker
+	propagate bool
+	// guards queue and closed, qcond is signalled when either changes
+	qmu   sync.Mutex
+	qcond *sync.Cond
+	// the work waiting for Start, and the most that can wait before queueing blocks
+	queue  []*Work
+	size   int
+	closed bool
+	// work running outside of Start's loop
+	running sync.WaitGroup
+	// closed once the queue is closed, and all work has finished
+	stopped chan struct{}
 }
 
-func newWorkChan(buff int64) *workChan {
-	return &workChan{
-		CachedResults: make(map[string]*Work),
-		queue:         make(chan *Work, buff),
+// the sides of a gate
+const (
+	readers = iota
+	writers
+)
+
+// gate lets in one side at a time, as many of it as come, while the other side waits.
+// Read only work is one side, and keyed work, which writes to the Config alongside other keyed work, the other.
+// Once a side waits, the other side stops letting more in, so neither side waits forever.
+type gate struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	in      [2]int
+	waiting [2]int
+	// the side let in next, when both are waiting
+	turn int
+}
+
+// enter waits till no one of the other side is in, and lets side in
+func (g *gate) enter(side int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.cond == nil {
+		g.cond = sync.NewCond(&g.mu)
+	}
+	other := 1 - side
+	if g.in[other] > 0 {
+		g.turn = side
+	}
+	g.waiting[side]++
+	for g.in[other] > 0 || (g.waiting[other] > 0 && g.turn != side) {
+		g.cond.Wait()
+	}
+	g.waiting[side]--
+	g.in[side]++
+	if g.waiting[other] > 0 {
+		g.turn = other
 	}
 }
-func (w *workChan) Start(conf *Config) {
-	for v := range w.queue {
-		w.CachedResults[v.Name] = v
-		v.do(conf)
+
+// leave lets the other side in, once the last of side leaves
+func (g *gate) leave(side int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.in[side]--; g.in[side] == 0 {
+		g.cond.Broadcast()
 	}
 }
-func (w *workChan) Stop() {
-	defer func() {
-		if r := recover(); r != nil {
-			fmt.Printf("channel was probably closed already: %v\n", r)
+
+// newWorkChan returns a queue of buff work, that runs up to workers work at a time,
+// and records the work it starts in history.
+// workers, or buff, less than 1 is treated as 1.
+func newWorkChan(buff int64, workers int, history *History) *workChan {
+	if workers < 1 {
+		workers = 1
+	}
+	if buff < 1 {
+		buff = 1
+	}
+	w := &workChan{
+		history:   history,
+		size:      int(buff),
+		active:    make(map[*Work]struct{}),
+		lastByKey: make(map[string]*Work),
+		workers:   make(chan struct{}, workers),
+		stopped:   make(chan struct{}),
+	}
+	w.qcond = sync.NewCond(&w.qmu)
+	return w
+}
+
+// read calls f while holding the Config for reading, like read only work
+func (w *workChan) read(f func()) {
+	w.conf.RLock()
+	defer w.conf.RUnlock()
+	w.gate.enter(readers)
+	defer w.gate.leave(readers)
+	f()
+}
+
+// write calls f while holding the Config for writing, like work without a concurrency key
+func (w *workChan) write(f func()) {
+	w.conf.Lock()
+	defer w.conf.Unlock()
+	f()
+}
+
+// Start takes work off the queue in order.
+// Work without a concurrency key, or that must run alone, waits for all running work to finish, and runs alone.
+// Keyed work runs on one of the workers, after the work before it with the same key has finished.
+// Once Stop is called, and the queue is empty, Start waits for running work and returns.
+func (w *workChan) Start(conf *Config) {
+	defer close(w.stopped)
+	defer w.running.Wait()
+	for {
+		v := w.Dequeue()
+		if v == nil {
+			break
 		}
+		// canceled while it was queued
+		if v.Done() {
+			continue
+		}
+		w.cache(v)
+		if v.key == "" || v.alone {
+			w.conf.Lock()
+			v.do(conf, w.propagate)
+			w.conf.Unlock()
+			continue
+		}
+		w.conf.RLock()
+		w.workers <- struct{}{}
+		w.mu.Lock()
+		prev := w.lastByKey[v.key]
+		w.lastByKey[v.key] = v
+		w.mu.Unlock()
+		w.running.Add(1)
+		go func(v, prev *Work) {
+			defer w.running.Done()
+			if prev != nil {
+				<-prev.wait
+			}
+			w.gate.enter(writers)
+			v.do(conf, w.propagate)
+			w.gate.leave(writers)
+			w.mu.Lock()
+			if w.lastByKey[v.key] == v {
+				delete(w.lastByKey, v.key)
+			}
+			w.mu.Unlock()
+			<-w.workers
+			w.conf.RUnlock()
+		}(v, prev)
+	}
+}
+
+// ReadOnly runs work right away without queueing it,
+// alongside keyed and other read only work, but never alongside work that needs the Config to itself
+// It returns Stopped if Stop has been called, otherwise accepted, if not nil, is called before the work can run,
+// and before Stop can return.
+func (w *workChan) ReadOnly(conf *Config, work *Work, accepted func()) error {
+	w.qmu.Lock()
+	defer w.qmu.Unlock()
+	if w.closed {
+		return Stopped
+	}
+	if accepted != nil {
+		accepted()
+	}
+	w.track(work)
+	w.cache(work)
+	w.running.Add(1)
+	go func() {
+		defer w.running.Done()
+		w.read(func() { work.do(conf, w.propagate) })
 	}()
-	close(w.queue)
+	return nil
 }
-func (w *workChan) Queue(work *Work) {
-	w.queue <- work
+func (w *workChan) cache(v *Work) {
+	w.history.Add(v)
 }
+
+// track remembers work as active till it is done
+func (w *workChan) track(work *Work) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for v := range w.active {
+		if v.Done() {
+			delete(w.active, v)
+		}
+	}
+	w.active[work] = struct{}{}
+}
+
+// Active returns the queued and running work named name, or all of it when name is ""
+func (w *workChan) Active(name string) (out []*Work) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for v := range w.active {
+		if v.Done() {
+			delete(w.active, v)
+		} else if name == "" || v.Name == name {
+			out = append(out, v)
+		}
+	}
+	return
+}
+
+// Stop stops accepting work, and releases Queue calls waiting for room with Stopped.
+// Work that is already queued is still ran. It never blocks, and is safe to call more than once.
+func (w *workChan) Stop() {
+	w.qmu.Lock()
+	defer w.qmu.Unlock()
+	w.closed = true
+	w.qcond.Broadcast()
+}
+
+// Closed reports whether Stop has been called
+func (w *workChan) Closed() bool {
+	w.qmu.Lock()
+	defer w.qmu.Unlock()
+	return w.closed
+}
+
+// Accept calls accepted for work that is not queued, like Queue does for queued work.
+// It returns Stopped, without calling accepted, if Stop has been called.
+func (w *workChan) Accept(accepted func()) error {
+	w.qmu.Lock()
+	defer w.qmu.Unlock()
+	if w.closed {
+		return Stopped
+	}
+	accepted()
+	return nil
+}
+
+// Stopped returns a channel that is closed once Stop has been called, and all work has finished
+func (w *workChan) Stopped() <-chan struct{} { return w.stopped }
+
+// Queue adds work to the end of the queue, waiting for room while the queue is full.
+// Work canceled while it is queued is taken off the queue, and makes room right away.
+// It returns Stopped if Stop has been called, before or while waiting,
+// otherwise accepted, if not nil, is called as the work is queued, and before Stop can return.
+func (w *workChan) Queue(work *Work, accepted func()) error {
+	w.qmu.Lock()
+	defer w.qmu.Unlock()
+	for !w.closed && len(w.queue) >= w.size {
+		w.qcond.Wait()
+	}
+	if w.closed {
+		return Stopped
+	}
+	if accepted != nil {
+		accepted()
+	}
+	w.track(work)
+	work.mu.Lock()
+	defer work.mu.Unlock()
+	// canceled before it was queued
+	if work.done {
+		return nil
+	}
+	work.unqueue = func() { w.remove(work) }
+	w.queue = append(w.queue, work)
+	w.qcond.Broadcast()
+	return nil
+}
+
+// remove takes work off the queue, if it is still queued
+func (w *workChan) remove(work *Work) {
+	w.qmu.Lock()
+	defer w.qmu.Unlock()
+	for i, v := range w.queue {
+		if v == work {
+			n := copy(w.queue[i:], w.queue[i+1:])
+			w.queue[i+n] = nil
+			w.queue = w.queue[:i+n]
+			w.qcond.Broadcast()
+			return
+		}
+	}
+}
+
+// Dequeue takes the first work off the queue, waiting for work while it is empty.
+// It returns nil once Stop has been called, and the queue is empty.
 func (w *workChan) Dequeue() *Work {
-	return <-w.queue
+	w.qmu.Lock()
+	defer w.qmu.Unlock()
+	for !w.closed && len(w.queue) == 0 {
+		w.qcond.Wait()
+	}
+	if len(w.queue) == 0 {
+		return nil
+	}
+	v := w.queue[0]
+	w.queue[0] = nil
+	w.queue = w.queue[1:]
+	w.qcond.Broadcast()
+	return v
 }
 func TypeConvertErr(from, to interface{}) error {
 	return fmt.Errorf("could not convert %#v, (%T) to %T", from, from, to)
@@ -0,0 +1,95 @@
+package commander
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestKVParse(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "exists")
+	if err := ioutil.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	choice := NewKV("color?", "color", CHOICE).WithChoices("red", "blue")
+	for _, v := range []struct {
+		kv   KV
+		s    string
+		want interface{}
+		bad  bool
+	}{
+		{kv: NewKV("", "s", STR), s: " a b ", want: " a b "},
+		{kv: NewKV("", "i", INT), s: "-42", want: int64(-42)},
+		{kv: NewKV("", "i", INT), s: "1.5", bad: true},
+		{kv: NewKV("", "f", FLO), s: "1.5", want: 1.5},
+		{kv: NewKV("", "f", FLO), s: "x", bad: true},
+		{kv: NewKV("", "b", BOOL), s: "Yes", want: true},
+		{kv: NewKV("", "b", BOOL), s: "0", want: false},
+		{kv: NewKV("", "b", BOOL), s: "maybe", bad: true},
+		{kv: NewKV("", "d", DUR), s: "1m30s", want: 90 * time.Second},
+		{kv: NewKV("", "d", DUR), s: "90", bad: true},
+		{kv: choice, s: "blue", want: "blue"},
+		{kv: choice, s: "green", bad: true},
+		{kv: NewKV("", "l", LIST), s: " a, b,,c ", want: []string{"a", "b", "c"}},
+		{kv: NewKV("", "l", LIST), s: "", want: []string{}},
+		{kv: NewKV("", "p", PATH), s: file, want: file},
+		{kv: NewKV("", "p", PATH), s: filepath.Join(dir, "missing"), bad: true},
+		{kv: NewKV("", "j", JSON), s: `{"a": [1]}`, want: map[string]interface{}{"a": []interface{}{1.0}}},
+		{kv: NewKV("", "j", JSON), s: `{`, bad: true},
+		{kv: KV{Key: "u", Hint: ScanType(99)}, s: "x", bad: true},
+	} {
+		got, err := v.kv.Parse(v.s)
+		if v.bad {
+			if err == nil {
+				t.Errorf("%s: parsed %q as %#v", v.kv.Key, v.s, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, v.want) {
+			t.Errorf("%s: parsed %q as %#v, %v, want %#v", v.kv.Key, v.s, got, err, v.want)
+		}
+	}
+}
+
+func TestKVCoerce(t *testing.T) {
+	for _, v := range []struct {
+		kv   KV
+		v    interface{}
+		want interface{}
+		bad  bool
+	}{
+		{kv: NewKV("", "i", INT), v: nil, want: int64(0)},
+		{kv: NewKV("", "i", INT).WithDefault(int64(7)), v: nil, want: int64(7)},
+		{kv: NewKV("", "i", INT), v: int64(3), want: int64(3)},
+		{kv: NewKV("", "i", INT), v: 3.0, want: int64(3)},
+		{kv: NewKV("", "i", INT), v: 1.5, bad: true},
+		{kv: NewKV("", "i", INT), v: "12", want: int64(12)},
+		{kv: NewKV("", "i", INT), v: true, bad: true},
+		{kv: NewKV("", "f", FLO), v: 1.5, want: 1.5},
+		{kv: NewKV("", "b", BOOL), v: false, want: false},
+		{kv: NewKV("", "b", BOOL), v: "y", want: true},
+		{kv: NewKV("", "d", DUR), v: float64(time.Second), want: time.Second},
+		{kv: NewKV("", "d", DUR), v: "2s", want: 2 * time.Second},
+		{kv: NewKV("", "d", DUR), v: time.Minute, want: time.Minute},
+		{kv: NewKV("", "l", LIST), v: []interface{}{"a", 1.0}, want: []string{"a", "1"}},
+		{kv: NewKV("", "l", LIST), v: "a,b", want: []string{"a", "b"}},
+		{kv: NewKV("", "l", LIST), v: 1.0, bad: true},
+		{kv: NewKV("", "s", STR), v: 1.0, bad: true},
+		{kv: NewKV("", "j", JSON), v: "a,b", want: "a,b"},
+		{kv: NewKV("", "j", JSON), v: []interface{}{1.0}, want: []interface{}{1.0}},
+	} {
+		got, err := v.kv.Coerce(v.v)
+		if v.bad {
+			if err == nil {
+				t.Errorf("%s: coerced %#v to %#v", v.kv.Key, v.v, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, v.want) {
+			t.Errorf("%s: coerced %#v to %#v, %v, want %#v", v.kv.Key, v.v, got, err, v.want)
+		}
+	}
+}
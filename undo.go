@@ -0,0 +1,102 @@
+package commander
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Cloner copies a Config, so Commands can go back to it with undo
+type Cloner interface {
+	Clone(Config) (Config, error)
+}
+
+// CloneFunc is a function that satisfies Cloner
+type CloneFunc func(Config) (Config, error)
+
+func (f CloneFunc) Clone(c Config) (Config, error) { return f(c) }
+
+// JSONCloner returns a Cloner that copies the Config with a json round trip into a new T.
+// T must be the concrete type held by the Config, a pointer type works as well as a value type.
+// Only what json encodes is copied, unexported fields are lost.
+func JSONCloner[T any]() Cloner {
+	return CloneFunc(func(c Config) (Config, error) {
+		b, err := json.Marshal(c)
+		if err != nil {
+			return nil, err
+		}
+		var t T
+		if err := json.Unmarshal(b, &t); err != nil {
+			return nil, err
+		}
+		return t, nil
+	})
+}
+
+// the Config before the work named name ran
+type snapshot struct {
+	name string
+	conf Config
+}
+
+// undoStack keeps the snapshots undo and redo go back and forth between
+type undoStack struct {
+	mu     sync.Mutex
+	cloner Cloner
+	depth  int
+	undo   []snapshot
+	redo   []snapshot
+}
+
+// save snapshots conf before the work named name runs.
+// The snapshot is only kept once keep is called, after the work succeeded, so failed work is not undone.
+// keep drops the oldest snapshot past depth, and clears redo, new work can not be redone after.
+func (u *undoStack) save(name string, conf *Config) (keep func(), err error) {
+	clone, err := u.cloner.Clone(*conf)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		u.undo = append(u.undo, snapshot{name: name, conf: clone})
+		if len(u.undo) > u.depth {
+			u.undo = u.undo[len(u.undo)-u.depth:]
+		}
+		u.redo = nil
+	}, nil
+}
+
+// back moves conf to the top snapshot of from, and saves the current conf on to.
+// It returns the name of the work that was undone or redone.
+func (u *undoStack) back(conf *Config, from, to *[]snapshot, what string) (string, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if len(*from) == 0 {
+		return "", fmt.Errorf("nothing to %s", what)
+	}
+	top := (*from)[len(*from)-1]
+	current, err := u.cloner.Clone(*conf)
+	if err != nil {
+		return "", err
+	}
+	*from = (*from)[:len(*from)-1]
+	*to = append(*to, snapshot{name: top.name, conf: current})
+	*conf = top.conf
+	return top.name, nil
+}
+
+// Undo sets conf back to what it was before the last work ran
+func (u *undoStack) Undo(conf *Config) (string, error) { return u.back(conf, &u.undo, &u.redo, "undo") }
+
+// Redo sets conf back to what it was before the last Undo
+func (u *undoStack) Redo(conf *Config) (string, error) { return u.back(conf, &u.redo, &u.undo, "redo") }
+
+// unsnapshotted is implemented by actions whose work should not be undone, undo and redo themselves
+type unsnapshotted interface {
+	unsnapshotted()
+}
+
+type unsnapshottedAction struct{ Action }
+
+func (unsnapshottedAction) unsnapshotted() {}
@@ -4,11 +4,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/user"
 	"path"
-	"strconv"
-	"strings"
+	"path/filepath"
+	"unicode"
 )
 
 func retry(times int, f func() error) error {
@@ -89,45 +90,18 @@ func prompt(argName string) string {
 	return fmt.Sprintf("%s | enter: ", argName)
 }
 
-// it would be cool if we could split this on space, and all the additional strings could be used as default arguments
-// TODO move scan to the commands interface so it can run commands in the middle of a question for lists and the like
-func scan(question string, pointer interface{}) error {
-	fmt.Printf("%s\n>>> ", question)
-	if _, err := fmt.Scanln(pointer); err != nil && !strings.Contains(err.Error(), "unexpected newline") {
-		return err
-	}
-	return nil
-}
-
-func scanIntOrDefault(msg string, def int64) (out int64) {
-	var err error
-	var temp string
-
-	out = def
-	err = scan(msg, &temp)
-	if err == nil {
-		out, err = strconv.ParseInt(temp, 10, 64)
-	}
-	if err != nil {
-		fmt.Printf("error scanning: %v\n", err)
-	}
-	return
-}
-
-func scanner(q string, pointer interface{}) func() error {
-	return func() error { return scan(q, pointer) }
-}
-func prettyB(b []byte) []byte {
+// prettyB indents the json in b, b is returned as is along with the error if it is not json
+func prettyB(b []byte) ([]byte, error) {
 	var out bytes.Buffer
 	if err := json.Indent(&out, b, "", "  "); err != nil {
-		fmt.Printf("error pretty printing: \n%v\n, returing original", err)
-		return b
+		return b, fmt.Errorf("error pretty printing: %v", err)
 	}
-	return out.Bytes()
+	return out.Bytes(), nil
 }
 
-func prettyS(s string) string {
-	return string(prettyB([]byte(s)))
+func prettyS(s string) (string, error) {
+	b, err := prettyB([]byte(s))
+	return string(b), err
 }
 func prettyJ(data interface{}) string {
 	buffer := new(bytes.Buffer)
@@ -144,27 +118,122 @@ func prettyJ(data interface{}) string {
 // it returns a json string with an error field, and string message
 func PrettyJson(data interface{}) string { return prettyJ(data) }
 
-func dashes(s string) {
-	fmt.Printf("---------%s---------\n", s)
-}
-
-func ReplaceHome(s *string) {
+// ReplaceHome replaces a leading ~ in s with the current user's home directory.
+// s is left as is when the home directory can not be found.
+func ReplaceHome(s *string) error {
 	if s != nil && len(*s) > 0 && (*s)[0] == '~' {
 		user, err := user.Current()
 		if err != nil {
-			fmt.Printf("error replacing ~ in \n%s\n error: %v\n", *s, err)
-			return
+			return fmt.Errorf("error replacing ~ in %s: %v", *s, err)
 		}
 		*s = path.Clean(path.Join(user.HomeDir, (*s)[1:]))
 	}
+	return nil
 }
-func ReplaceDotSlash(s *string) {
+
+// ReplaceDotSlash replaces a leading ./ in s with the working directory.
+// s is left as is when the working directory can not be found.
+func ReplaceDotSlash(s *string) error {
 	if s != nil && len(*s) > 1 && (*s)[0:2] == "./" {
 		dir, err := os.Getwd()
 		if err != nil {
-			fmt.Printf("error replacing ./ in \n%s\n error: %v", s, err)
-			return
+			return fmt.Errorf("error replacing ./ in %s: %v", *s, err)
 		}
 		*s = path.Clean(path.Join(dir, (*s)[2:]))
 	}
+	return nil
+}
+
+// expandPath calls ReplaceDotSlash, then ReplaceHome on s
+func expandPath(s *string) error {
+	if err := ReplaceDotSlash(s); err != nil {
+		return err
+	}
+	return ReplaceHome(s)
+}
+
+// WriteFileAtomic writes data to a temporary file next to filename, then renames it over filename,
+// so a crash never leaves filename half written.
+func WriteFileAtomic(filename string, data []byte, perm os.FileMode) (err error) {
+	f, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	if _, err = f.Write(data); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Chmod(perm); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filename)
+}
+
+// SplitArgs splits line into words the way a shell would.
+// Words are separated by unquoted whitespace.  Single quotes keep everything between them literally,
+// double quotes keep whitespace, and a backslash escapes the next character outside single quotes.
+// An unterminated quote, or a trailing backslash, is an error.
+func SplitArgs(line string) ([]string, error) {
+	var (
+		out     []string
+		word    []rune
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range line {
+		switch {
+		case escaped:
+			word = append(word, r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word = append(word, r)
+			}
+		case r == '\\':
+			escaped = true
+			inWord = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				word = append(word, r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case unicode.IsSpace(r):
+			if inWord {
+				out = append(out, string(word))
+				word = word[:0]
+				inWord = false
+			}
+		default:
+			word = append(word, r)
+			inWord = true
+		}
+	}
+	if escaped {
+		return nil, fmt.Errorf("trailing backslash in: %s", line)
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote in: %s", quote, line)
+	}
+	if inWord {
+		out = append(out, string(word))
+	}
+	return out, nil
 }
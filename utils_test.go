@@ -0,0 +1,43 @@
+package commander
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	for _, v := range []struct {
+		line string
+		want []string
+		err  string
+	}{
+		{line: "", want: nil},
+		{line: " \t ", want: nil},
+		{line: "save  a.json\tb", want: []string{"save", "a.json", "b"}},
+		{line: `say "hello world"`, want: []string{"say", "hello world"}},
+		{line: `say 'hello  world'`, want: []string{"say", "hello  world"}},
+		{line: `a"b c"d`, want: []string{"ab cd"}},
+		{line: `'' ""`, want: []string{"", ""}},
+		{line: `'a "b" \c'`, want: []string{`a "b" \c`}},
+		{line: `"a 'b' \"c\""`, want: []string{`a 'b' "c"`}},
+		{line: `a\ b \'c`, want: []string{"a b", "'c"}},
+		{line: `\\`, want: []string{`\`}},
+		{line: `--name="x y" -f`, want: []string{"--name=x y", "-f"}},
+		{line: "héllo wörld", want: []string{"héllo", "wörld"}},
+		{line: `say "hello`, err: `unterminated " quote`},
+		{line: `say 'hello`, err: "unterminated ' quote"},
+		{line: `say hello\`, err: "trailing backslash"},
+	} {
+		got, err := SplitArgs(v.line)
+		if v.err != "" {
+			if err == nil || !strings.Contains(err.Error(), v.err) {
+				t.Errorf("%s: error is %v, want %q", v.line, err, v.err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, v.want) {
+			t.Errorf("%s: split into %q, %v, want %q", v.line, got, err, v.want)
+		}
+	}
+}
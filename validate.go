@@ -0,0 +1,78 @@
+package commander
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Validator checks a parsed KV answer, returning an error that explains why it is not acceptable
+type Validator func(interface{}) error
+
+// Min returns a Validator that rejects numbers and durations less than min.
+// Strings and lists are rejected when their length is less than min.
+// Durations are compared in nanoseconds, Min(float64(time.Second)) rejects anything under a second.
+func Min(min float64) Validator {
+	return func(v interface{}) error {
+		n, what, err := measure(v)
+		if err != nil {
+			return err
+		}
+		if n < min {
+			return fmt.Errorf("%s must be at least %v, got %v", what, min, n)
+		}
+		return nil
+	}
+}
+
+// Max returns a Validator that rejects numbers and durations greater than max.
+// Strings and lists are rejected when their length is greater than max.
+func Max(max float64) Validator {
+	return func(v interface{}) error {
+		n, what, err := measure(v)
+		if err != nil {
+			return err
+		}
+		if n > max {
+			return fmt.Errorf("%s must be at most %v, got %v", what, max, n)
+		}
+		return nil
+	}
+}
+
+// Regex returns a Validator that rejects strings that do not match pattern.
+// It panics if pattern does not compile.
+func Regex(pattern string) Validator {
+	re := regexp.MustCompile(pattern)
+	return func(v interface{}) error {
+		s, ok := v.(string)
+		if !ok {
+			return TypeConvertErr(v, "")
+		}
+		if !re.MatchString(s) {
+			return fmt.Errorf("%q does not match %s", s, pattern)
+		}
+		return nil
+	}
+}
+
+// measure returns the number Min and Max compare against, and what that number is
+func measure(v interface{}) (float64, string, error) {
+	switch t := v.(type) {
+	case int64:
+		return float64(t), "value", nil
+	case int:
+		return float64(t), "value", nil
+	case float64:
+		return t, "value", nil
+	case time.Duration:
+		return float64(t), "duration", nil
+	case string:
+		return float64(len(t)), "length", nil
+	case []string:
+		return float64(len(t)), "length", nil
+	case []interface{}:
+		return float64(len(t)), "length", nil
+	}
+	return 0, "", fmt.Errorf("can not compare %#v (%T) to a number", v, v)
+}